@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"vkane.cz/tinyquiz/pkg/gameCreator"
 	"vkane.cz/tinyquiz/pkg/model"
 	"vkane.cz/tinyquiz/pkg/model/ent"
+	"vkane.cz/tinyquiz/pkg/model/ent/question"
+	"vkane.cz/tinyquiz/pkg/model/ent/session"
 	"vkane.cz/tinyquiz/pkg/rtcomm"
 )
 
@@ -18,16 +26,49 @@ func (app *application) homeSuccess(w http.ResponseWriter, r *http.Request, para
 	app.home(w, r, homeForm{}, http.StatusOK)
 }
 
+const defaultBreakLength = "10"
+const maxBreakLength = 10 * time.Minute
+const defaultMinPlayers = "1"
+const maxMinPlayers = 1000
+const deadlineFormat = "2006-01-02T15:04" // as sent by datetime-local inputs
+
+// names and colours of teams in the order they are created, which also limits the number of teams
+var teamPalette = []model.TeamOptions{
+	{Name: "Červení", Colour: "#e53935"},
+	{Name: "Modří", Colour: "#1e88e5"},
+	{Name: "Zelení", Colour: "#43a047"},
+	{Name: "Žlutí", Colour: "#fdd835"},
+	{Name: "Fialoví", Colour: "#8e24aa"},
+	{Name: "Oranžoví", Colour: "#fb8c00"},
+	{Name: "Tyrkysoví", Colour: "#00acc1"},
+	{Name: "Růžoví", Colour: "#d81b60"},
+}
+
 type homeForm struct {
 	Join struct {
 		Code   string
 		Name   string
+		Team   string
 		Errors []string
 	}
 	NewSession struct {
-		Code   string
-		Name   string
-		Errors []string
+		Code             string
+		Name             string
+		Scoring          string
+		AutoAdvance      bool
+		Autopilot        bool
+		BreakLength      string
+		MinPlayers       string
+		Teams            string
+		TeamScoring      string
+		SelfPaced        bool
+		Deadline         string
+		PartialCredit    bool
+		ShuffleQuestions bool
+		ShuffleChoices   string
+		PoolSize         string
+		PoolByCategory   bool
+		Errors           []string
 	}
 	NewGame struct {
 		Title  string
@@ -44,6 +85,12 @@ func (app *application) home(w http.ResponseWriter, r *http.Request, formData ho
 	}
 	td := &homeData{}
 	td.Form = formData
+	if td.Form.NewSession.BreakLength == "" {
+		td.Form.NewSession.BreakLength = defaultBreakLength
+	}
+	if td.Form.NewSession.MinPlayers == "" {
+		td.Form.NewSession.MinPlayers = defaultMinPlayers
+	}
 	setDefaultTemplateData(&td.templateData)
 
 	if stats, err := app.model.GetStats(r.Context()); err == nil {
@@ -68,9 +115,11 @@ func (app *application) play(w http.ResponseWriter, r *http.Request, params http
 
 	var code = strings.TrimSpace(params.ByName("code"))
 	var player = strings.ToLower(strings.TrimSpace(r.PostForm.Get("player")))
+	var team = strings.TrimSpace(r.PostForm.Get("team"))
 	var form homeForm
 	form.Join.Code = code
 	form.Join.Name = player
+	form.Join.Team = team
 
 	if len(player) < 1 {
 		form.Join.Errors = []string{"Zadejte jméno hráče"}
@@ -78,10 +127,11 @@ func (app *application) play(w http.ResponseWriter, r *http.Request, params http
 		return
 	}
 
-	if player, err := app.model.RegisterPlayer(player, code, time.Now(), r.Context()); err == nil {
+	if player, err := app.model.RegisterPlayer(player, team, code, time.Now(), r.Context()); err == nil {
 		if session, err := player.Unwrap().QuerySession().Only(r.Context()); err == nil {
 			if su, err := app.model.GetPlayersStateUpdate(session.ID, r.Context()); err == nil {
 				app.rtClients.SendToAll(session.ID, su)
+				app.autoAdvance(session.ID)
 			} else {
 				app.serverError(w, err)
 				return
@@ -100,6 +150,14 @@ func (app *application) play(w http.ResponseWriter, r *http.Request, params http
 		form.Join.Errors = []string{"Hráč s tímto jménem již existuje"}
 		app.home(w, r, form, http.StatusForbidden)
 		return
+	} else if errors.Is(err, model.Banned) {
+		form.Join.Errors = []string{"Pod tímto jménem se do hry nelze připojit"}
+		app.home(w, r, form, http.StatusForbidden)
+		return
+	} else if errors.Is(err, model.NoSuchTeam) {
+		form.Join.Errors = []string{"Tým s tímto názvem ve hře není"}
+		app.home(w, r, form, http.StatusNotFound)
+		return
 	} else {
 		app.serverError(w, err)
 		return
@@ -115,9 +173,24 @@ func (app *application) createSession(w http.ResponseWriter, r *http.Request, pa
 
 	var code = strings.TrimSpace(r.PostForm.Get("code"))
 	var player = strings.ToLower(strings.TrimSpace(r.PostForm.Get("organiser")))
+	var scoring = session.Scoring(r.PostForm.Get("scoring"))
 	var form homeForm
 	form.NewSession.Code = code
 	form.NewSession.Name = player
+	form.NewSession.Scoring = string(scoring)
+	form.NewSession.AutoAdvance = r.PostForm.Get("autoAdvance") != ""
+	form.NewSession.Autopilot = r.PostForm.Get("autopilot") != ""
+	form.NewSession.BreakLength = strings.TrimSpace(r.PostForm.Get("breakLength"))
+	form.NewSession.MinPlayers = strings.TrimSpace(r.PostForm.Get("minPlayers"))
+	form.NewSession.Teams = strings.TrimSpace(r.PostForm.Get("teams"))
+	form.NewSession.TeamScoring = r.PostForm.Get("teamScoring")
+	form.NewSession.SelfPaced = r.PostForm.Get("selfPaced") != ""
+	form.NewSession.Deadline = strings.TrimSpace(r.PostForm.Get("deadline"))
+	form.NewSession.PartialCredit = r.PostForm.Get("partialCredit") != ""
+	form.NewSession.ShuffleQuestions = r.PostForm.Get("shuffleQuestions") != ""
+	form.NewSession.ShuffleChoices = r.PostForm.Get("shuffleChoices")
+	form.NewSession.PoolSize = strings.TrimSpace(r.PostForm.Get("poolSize"))
+	form.NewSession.PoolByCategory = r.PostForm.Get("poolByCategory") != ""
 
 	if len(player) < 1 {
 		form.NewSession.Errors = []string{"Zadejte jméno organizátora"}
@@ -125,9 +198,112 @@ func (app *application) createSession(w http.ResponseWriter, r *http.Request, pa
 		return
 	}
 
-	if s, p, err := app.model.CreateSession(player, code, time.Now(), r.Context()); err == nil {
+	if scoring == "" {
+		scoring = session.DefaultScoring
+	} else if err := session.ScoringValidator(scoring); err != nil {
+		form.NewSession.Errors = []string{"Zvolte platný způsob bodování"}
+		app.home(w, r, form, http.StatusBadRequest)
+		return
+	}
+
+	var breakLength time.Duration
+	var minPlayers uint64
+	if form.NewSession.Autopilot {
+		if seconds, err := strconv.ParseUint(form.NewSession.BreakLength, 10, 32); err == nil && time.Duration(seconds)*time.Second <= maxBreakLength {
+			breakLength = time.Duration(seconds) * time.Second
+		} else {
+			form.NewSession.Errors = []string{"Zadejte délku přestávky v sekundách (nejvýše 10 minut)"}
+			app.home(w, r, form, http.StatusBadRequest)
+			return
+		}
+		if count, err := strconv.ParseUint(form.NewSession.MinPlayers, 10, 32); err == nil && count >= 1 && count <= maxMinPlayers {
+			minPlayers = count
+		} else {
+			form.NewSession.Errors = []string{"Zadejte, na kolik hráčů se má čekat (1 až " + strconv.Itoa(maxMinPlayers) + ")"}
+			app.home(w, r, form, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var teams []model.TeamOptions
+	if form.NewSession.Teams != "" {
+		if count, err := strconv.ParseUint(form.NewSession.Teams, 10, 8); err == nil && count <= uint64(len(teamPalette)) && count != 1 {
+			teams = teamPalette[:count]
+		} else {
+			form.NewSession.Errors = []string{"Zvolte žádný tým nebo 2 až 8 týmů"}
+			app.home(w, r, form, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var teamScoring = session.TeamScoring(form.NewSession.TeamScoring)
+	if teamScoring == "" {
+		teamScoring = session.DefaultTeamScoring
+	} else if err := session.TeamScoringValidator(teamScoring); err != nil {
+		form.NewSession.Errors = []string{"Zvolte platný způsob bodování týmů"}
+		app.home(w, r, form, http.StatusBadRequest)
+		return
+	}
+
+	var shuffleChoices = session.ShuffleChoices(form.NewSession.ShuffleChoices)
+	if shuffleChoices == "" {
+		shuffleChoices = session.DefaultShuffleChoices
+	} else if err := session.ShuffleChoicesValidator(shuffleChoices); err != nil {
+		form.NewSession.Errors = []string{"Zvolte platný způsob míchání odpovědí"}
+		app.home(w, r, form, http.StatusBadRequest)
+		return
+	}
+
+	var poolSize int
+	if form.NewSession.PoolSize != "" {
+		if size, err := strconv.ParseUint(form.NewSession.PoolSize, 10, 16); err == nil && size >= 1 && size <= maxQuestions {
+			poolSize = int(size)
+		} else {
+			form.NewSession.Errors = []string{"Zadejte počet otázek od 1 do " + strconv.Itoa(maxQuestions) + ", nebo nechte pole prázdné pro všechny otázky"}
+			app.home(w, r, form, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var deadline time.Time
+	if form.NewSession.SelfPaced {
+		if form.NewSession.AutoAdvance || form.NewSession.Autopilot {
+			form.NewSession.Errors = []string{"Hru vlastním tempem nelze kombinovat s automatickým během"}
+			app.home(w, r, form, http.StatusBadRequest)
+			return
+		}
+		if form.NewSession.Deadline != "" {
+			if d, err := time.ParseInLocation(deadlineFormat, form.NewSession.Deadline, time.Local); err == nil && d.After(time.Now()) {
+				deadline = d
+			} else {
+				form.NewSession.Errors = []string{"Zadejte termín odevzdání v budoucnosti"}
+				app.home(w, r, form, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	var options = model.SessionOptions{
+		Scoring:          scoring,
+		AutoAdvance:      form.NewSession.AutoAdvance,
+		Autopilot:        form.NewSession.Autopilot,
+		BreakLength:      breakLength,
+		MinPlayers:       minPlayers,
+		Teams:            teams,
+		TeamScoring:      teamScoring,
+		SelfPaced:        form.NewSession.SelfPaced,
+		Deadline:         deadline,
+		PartialCredit:    form.NewSession.PartialCredit,
+		ShuffleQuestions: form.NewSession.ShuffleQuestions,
+		ShuffleChoices:   shuffleChoices,
+		PoolSize:         poolSize,
+		PoolByCategory:   form.NewSession.PoolByCategory,
+	}
+
+	if s, p, err := app.model.CreateSession(player, code, options, time.Now(), r.Context()); err == nil {
 		if su, err := app.model.GetPlayersStateUpdate(s.ID, r.Context()); err == nil {
 			app.rtClients.SendToAll(s.ID, su)
+			app.autoAdvance(s.ID)
 			http.Redirect(w, r, "/game/"+url.PathEscape(p.ID.String()), http.StatusSeeOther)
 			return
 		} else {
@@ -154,14 +330,28 @@ func (app *application) game(w http.ResponseWriter, r *http.Request, params http
 	}
 
 	if player, err := app.model.GetPlayerWithSessionAndGame(playerUid, r.Context()); err == nil {
+		if player.Kicked != nil {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
 		type lobbyData struct {
-			P *ent.Player
+			P         *ent.Player
+			Questions []*ent.Question // for organisers only
 			templateData
 		}
 		td := &lobbyData{}
 		setDefaultTemplateData(&td.templateData)
 		td.P = player
 
+		if player.Organiser {
+			if questions, err := app.model.GetSessionQuestions(player.Edges.Session.ID, r.Context()); err == nil {
+				td.Questions = questions
+			} else {
+				app.serverError(w, err)
+				return
+			}
+		}
+
 		app.render(w, r, "game.page.tmpl.html", td)
 	} else if errors.Is(err, model.NoSuchEntity) {
 		app.clientError(w, http.StatusNotFound)
@@ -172,7 +362,8 @@ func (app *application) game(w http.ResponseWriter, r *http.Request, params http
 	}
 }
 
-func (app *application) nextQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+// Runs the action of an organiser moving the session to another question and notifies clients of the session
+func (app *application) navigate(w http.ResponseWriter, r *http.Request, params httprouter.Params, action func(organiserId uuid.UUID, now time.Time, c context.Context) error) {
 	var playerUid uuid.UUID
 	if uid, err := uuid.Parse(params.ByName("playerUid")); err == nil {
 		playerUid = uid
@@ -184,9 +375,10 @@ func (app *application) nextQuestion(w http.ResponseWriter, r *http.Request, par
 	if player, err := app.model.GetPlayerWithSessionAndGame(playerUid, r.Context()); err == nil {
 		var sessionId = player.Edges.Session.ID
 		var now = time.Now()
-		if err := app.model.NextQuestion(sessionId, now, r.Context()); err == nil {
+		if err := action(playerUid, now, r.Context()); err == nil {
 			if su, err := app.model.GetQuestionStateUpdate(sessionId, now, r.Context()); err == nil {
 				app.rtClients.SendToAll(sessionId, su)
+				app.autoAdvance(sessionId)
 				w.WriteHeader(http.StatusNoContent)
 				return
 			} else {
@@ -197,11 +389,231 @@ func (app *application) nextQuestion(w http.ResponseWriter, r *http.Request, par
 			app.rtClients.SendToAll(sessionId, rtcomm.StateUpdate{Results: true})
 			w.WriteHeader(http.StatusNoContent)
 			return
+		} else if errors.Is(err, model.Forbidden) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		} else if errors.Is(err, model.NotLive) {
+			app.clientError(w, http.StatusConflict)
+			return
+		} else if errors.Is(err, model.NoSuchEntity) {
+			app.clientError(w, http.StatusNotFound)
+			return
+		} else {
+			app.serverError(w, err)
+			return
+		}
+	} else if errors.Is(err, model.NoSuchEntity) {
+		app.clientError(w, http.StatusNotFound)
+		return
+	} else {
+		app.serverError(w, err)
+		return
+	}
+}
+
+func (app *application) nextQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.navigate(w, r, params, app.model.NextQuestion)
+}
+
+func (app *application) skipQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.navigate(w, r, params, app.model.SkipQuestion)
+}
+
+func (app *application) repeatQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.navigate(w, r, params, app.model.RepeatQuestion)
+}
+
+func (app *application) askQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var questionUid uuid.UUID
+	if uid, err := uuid.Parse(params.ByName("questionUid")); err == nil {
+		questionUid = uid
+	} else {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	app.navigate(w, r, params, func(organiserId uuid.UUID, now time.Time, c context.Context) error {
+		return app.model.AskQuestion(organiserId, questionUid, now, c)
+	})
+}
+
+// Runs the action of an organiser on the current question and notifies clients of the session about its corrected countdown
+func (app *application) controlQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params, action func(organiserId uuid.UUID, now time.Time, c context.Context) error) {
+	var playerUid uuid.UUID
+	if uid, err := uuid.Parse(params.ByName("playerUid")); err == nil {
+		playerUid = uid
+	} else {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if player, err := app.model.GetPlayerWithSessionAndGame(playerUid, r.Context()); err == nil {
+		var sessionId = player.Edges.Session.ID
+		var now = time.Now()
+		if err := action(playerUid, now, r.Context()); err == nil {
+			if su, err := app.model.GetTimerStateUpdate(sessionId, now, r.Context()); err == nil {
+				app.rtClients.SendToAll(sessionId, su)
+				app.autoAdvance(sessionId)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			} else {
+				app.serverError(w, err)
+				return
+			}
+		} else if errors.Is(err, model.Forbidden) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		} else if errors.Is(err, model.QuestionClosed) || errors.Is(err, model.NotLive) {
+			app.clientError(w, http.StatusConflict)
+			return
+		} else {
+			app.serverError(w, err)
+			return
+		}
+	} else if errors.Is(err, model.NoSuchEntity) {
+		app.clientError(w, http.StatusNotFound)
+		return
+	} else {
+		app.serverError(w, err)
+		return
+	}
+}
+
+func (app *application) pauseQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.controlQuestion(w, r, params, app.model.PauseQuestion)
+}
+
+func (app *application) resumeQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.controlQuestion(w, r, params, app.model.ResumeQuestion)
+}
+
+const defaultExtension = 15 * time.Second
+const maxExtension = 10 * time.Minute
+
+func (app *application) extendQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var by = defaultExtension
+	if s := r.PostForm.Get("seconds"); s != "" {
+		if seconds, err := strconv.ParseUint(s, 10, 32); err == nil && time.Duration(seconds)*time.Second <= maxExtension {
+			by = time.Duration(seconds) * time.Second
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	app.controlQuestion(w, r, params, func(organiserId uuid.UUID, now time.Time, c context.Context) error {
+		return app.model.ExtendQuestion(organiserId, by, now, c)
+	})
+}
+
+func (app *application) kickPlayer(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var playerUid uuid.UUID
+	if uid, err := uuid.Parse(params.ByName("playerUid")); err == nil {
+		playerUid = uid
+	} else {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var name = r.PostForm.Get("name")
+	var keepAnswers = r.PostForm.Get("keepAnswers") != ""
+	var ban = r.PostForm.Get("ban") != ""
+
+	if player, err := app.model.GetPlayerWithSessionAndGame(playerUid, r.Context()); err == nil {
+		var sessionId = player.Edges.Session.ID
+		if _, err := app.model.KickPlayer(playerUid, name, keepAnswers, ban, time.Now(), r.Context()); err == nil {
+			if su, err := app.model.GetPlayersStateUpdate(sessionId, r.Context()); err == nil {
+				su.Kicked = []string{name}
+				app.rtClients.SendToAll(sessionId, su)
+			} else {
+				app.serverError(w, err)
+				return
+			}
+			if su, err := app.model.GetProgressStateUpdate(sessionId, r.Context()); err == nil {
+				app.rtClients.SendToAll(sessionId, su)
+				app.autoAdvance(sessionId)
+			} else {
+				app.serverError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		} else if errors.Is(err, model.Forbidden) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		} else if errors.Is(err, model.NotLive) {
+			app.clientError(w, http.StatusConflict)
+			return
+		} else if errors.Is(err, model.NoSuchEntity) {
+			app.clientError(w, http.StatusNotFound)
+			return
+		} else {
+			app.serverError(w, err)
+			return
+		}
+	} else if errors.Is(err, model.NoSuchEntity) {
+		app.clientError(w, http.StatusNotFound)
+		return
+	} else {
+		app.serverError(w, err)
+		return
+	}
+}
+
+func (app *application) acceptAnswer(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var variant = r.PostForm.Get("text")
+	app.navigate(w, r, params, func(organiserId uuid.UUID, now time.Time, c context.Context) error {
+		return app.model.AcceptAnswer(organiserId, variant, now, c)
+	})
+}
+
+func (app *application) finishSession(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.navigate(w, r, params, app.model.FinishSession)
+}
+
+// Moves a player of a self-paced session to their next question and sends it to their clients only
+func (app *application) nextOwnQuestion(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var playerUid uuid.UUID
+	if uid, err := uuid.Parse(params.ByName("playerUid")); err == nil {
+		playerUid = uid
+	} else {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var now = time.Now()
+	if err := app.model.NextOwnQuestion(playerUid, now, r.Context()); err == nil || errors.Is(err, model.QuestionClosed) {
+		if su, err := app.model.GetOwnQuestionStateUpdate(playerUid, now, r.Context()); err == nil {
+			app.rtClients.SendToAll(playerUid, su)
 		} else {
 			app.serverError(w, err)
 			return
 		}
-	} else if ent.IsNotFound(err) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if errors.Is(err, model.NoNextQuestion) {
+		app.rtClients.SendToAll(playerUid, rtcomm.StateUpdate{Results: true})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if errors.Is(err, model.Forbidden) || errors.Is(err, model.Kicked) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	} else if errors.Is(err, model.NoSuchEntity) {
 		app.clientError(w, http.StatusNotFound)
 		return
 	} else {
@@ -227,10 +639,112 @@ func (app *application) answer(w http.ResponseWriter, r *http.Request, params ht
 		return
 	}
 
-	if _, err := app.model.SaveAnswer(playerUid, choiceUid, time.Now(), r.Context()); err == nil {
-		// TODO notify organisers
+	app.saveAnswer(w, r, playerUid, func(now time.Time, c context.Context) (*ent.Answer, error) {
+		return app.model.SaveAnswer(playerUid, choiceUid, now, c)
+	})
+}
+
+// answers a text question with the text posted in the text field, a number question with the number posted in the number field,
+// an ordering question with the choices posted in order in the order field or a multiple-choice question with the set of choices posted in the choice field
+func (app *application) answerSet(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var playerUid uuid.UUID
+	if uid, err := uuid.Parse(params.ByName("playerUid")); err == nil {
+		playerUid = uid
+	} else {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if text, ok := r.PostForm["text"]; ok {
+		app.saveAnswer(w, r, playerUid, func(now time.Time, c context.Context) (*ent.Answer, error) {
+			return app.model.SaveTextAnswer(playerUid, text[0], now, c)
+		})
+		return
+	}
+	if number, ok := r.PostForm["number"]; ok {
+		if n, err := gameCreator.ParseNumber(number[0]); err == nil {
+			app.saveAnswer(w, r, playerUid, func(now time.Time, c context.Context) (*ent.Answer, error) {
+				return app.model.SaveNumberAnswer(playerUid, n, now, c)
+			})
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+	var field = "choice"
+	if _, ok := r.PostForm["order"]; ok {
+		field = "order"
+	}
+	var choiceUids = make([]uuid.UUID, 0, len(r.PostForm[field]))
+	for _, choice := range r.PostForm[field] {
+		if uid, err := uuid.Parse(choice); err == nil {
+			choiceUids = append(choiceUids, uid)
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	if field == "order" {
+		app.saveAnswer(w, r, playerUid, func(now time.Time, c context.Context) (*ent.Answer, error) {
+			if answers, err := app.model.SaveOrder(playerUid, choiceUids, now, c); err == nil {
+				return answers[0], nil
+			} else {
+				return nil, err
+			}
+		})
+		return
+	}
+
+	app.saveAnswer(w, r, playerUid, func(now time.Time, c context.Context) (*ent.Answer, error) {
+		if answers, err := app.model.SaveAnswers(playerUid, choiceUids, now, c); err == nil {
+			return answers[0], nil
+		} else {
+			return nil, err
+		}
+	})
+}
+
+// Runs the action saving an answer of the player and notifies clients of the session about the progress
+func (app *application) saveAnswer(w http.ResponseWriter, r *http.Request, playerUid uuid.UUID, save func(now time.Time, c context.Context) (*ent.Answer, error)) {
+	var now = time.Now()
+	if a, err := save(now, r.Context()); err == nil {
+		if s, err := a.Unwrap().QueryAnswerer().QuerySession().Only(r.Context()); err == nil && s.SelfPaced {
+			if su, err := app.model.GetOwnQuestionStateUpdate(playerUid, now, r.Context()); err == nil {
+				app.rtClients.SendToAll(playerUid, su)
+			} else {
+				app.serverError(w, err)
+				return
+			}
+		} else if err == nil {
+			if su, err := app.model.GetProgressStateUpdate(s.ID, r.Context()); err == nil {
+				app.rtClients.SendToAll(s.ID, su)
+				app.autoAdvance(s.ID)
+			} else {
+				app.serverError(w, err)
+				return
+			}
+		} else {
+			app.serverError(w, err)
+			return
+		}
 		w.WriteHeader(http.StatusCreated) // TODO or StatusNoContent?
 		return
+	} else if errors.Is(err, model.QuestionPaused) {
+		app.clientError(w, http.StatusLocked)
+		return
+	} else if errors.Is(err, model.Kicked) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	} else if errors.Is(err, model.QuestionClosed) || errors.Is(err, model.AlreadyAnswered) {
+		app.clientError(w, http.StatusConflict)
+		return
+	} else if errors.Is(err, model.InvalidAnswer) {
+		app.clientError(w, http.StatusBadRequest)
+		return
 	} else if errors.Is(err, model.NoSuchEntity) {
 		app.clientError(w, http.StatusNotFound)
 		return
@@ -243,8 +757,14 @@ func (app *application) answer(w http.ResponseWriter, r *http.Request, params ht
 func (app *application) resultsGeneral(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	type resultsData struct {
 		Results []model.PlayerResult
-		Session *ent.Session
-		Player  *ent.Player
+		Teams   []model.TeamResult
+		Polls   []model.PollResult
+		Survey  bool // the game consists of polls only, so there is nothing to rank the players by
+		// the questions asked in the session, which may be just a pool drawn from the game
+		Questions []*ent.Question
+		Rounds    []*ent.Round // of the questions in their order
+		Session   *ent.Session
+		Player    *ent.Player
 		templateData
 	}
 	td := &resultsData{}
@@ -258,8 +778,9 @@ func (app *application) resultsGeneral(w http.ResponseWriter, r *http.Request, p
 		return
 	}
 
-	if results, session, player, err := app.model.GetResults(playerUid, r.Context()); err == nil {
+	if results, teams, session, player, err := app.model.GetResults(playerUid, r.Context()); err == nil {
 		td.Results = results
+		td.Teams = teams
 		td.Session = session
 		td.Player = player
 	} else if errors.Is(err, model.NoSuchEntity) {
@@ -270,6 +791,26 @@ func (app *application) resultsGeneral(w http.ResponseWriter, r *http.Request, p
 		return
 	}
 
+	if polls, err := app.model.GetPollResults(playerUid, r.Context()); err == nil {
+		td.Polls = polls
+	} else {
+		app.serverError(w, err)
+		return
+	}
+	if questions, err := app.model.GetSessionQuestions(td.Session.ID, r.Context()); err == nil {
+		td.Questions = questions
+	} else {
+		app.serverError(w, err)
+		return
+	}
+	td.Survey = len(td.Questions) > 0
+	for _, q := range td.Questions {
+		td.Survey = td.Survey && q.Type == question.TypePoll
+		if r := q.Edges.Round; r != nil && (len(td.Rounds) == 0 || td.Rounds[len(td.Rounds)-1].ID != r.ID) {
+			td.Rounds = append(td.Rounds, r)
+		}
+	}
+
 	app.render(w, r, "results.page.tmpl.html", td)
 }
 
@@ -283,10 +824,17 @@ func (app *application) downloadTemplate(w http.ResponseWriter, r *http.Request,
 	}
 }
 
+const maxUploadSize = 20000000 // the questions and the images together
+const maxQuestions = 500
+const maxChoicesPerQuestion = 100
+const maxImageSize = 2000000
+const maxImagesSize = 50000000 // uncompressed, as a bundle of images may be compressed well
+const maxImages = 200
+
 func (app *application) createGame(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	r.Body = http.MaxBytesReader(w, r.Body, 1000000)
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
 	// shall never write to temp files thanks to MaxBytesReader
-	if err := r.ParseMultipartForm(2000000); err != nil {
+	if err := r.ParseMultipartForm(2 * maxUploadSize); err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
@@ -302,18 +850,51 @@ func (app *application) createGame(w http.ResponseWriter, r *http.Request, param
 		return
 	}
 
-	if parsedGame, err := gameCreator.Parse(file, 500, 100); err == nil {
-		if game, err := app.model.CreateGame(parsedGame, name, author, r.Context()); err == nil {
-			http.Redirect(w, r, "/quiz/"+url.PathEscape(game.ID.String()), http.StatusSeeOther)
-			return
-		} else {
-			app.serverError(w, err)
+	var images map[string]gameCreator.Image
+	if bundle, header, err := r.FormFile("images"); err == nil {
+		if images, err = gameCreator.ReadImages(bundle, header.Size, maxImages, maxImageSize, maxImagesSize); err != nil {
+			form.NewGame.Errors = []string{"Balík obrázků není v pořádku (nejvýše " + strconv.Itoa(maxImages) + " obrázků PNG, JPEG, GIF nebo WebP, každý do " + strconv.Itoa(maxImageSize/1000000) + " MB a všechny dohromady do " + strconv.Itoa(maxImagesSize/1000000) + " MB)"}
+			app.home(w, r, form, http.StatusBadRequest)
 			return
 		}
-	} else {
+	}
+
+	if parsedGame, err := gameCreator.Parse(file, maxQuestions, maxChoicesPerQuestion); err != nil {
 		form.NewGame.Errors = []string{"Soubor s otázkami není v pořádku"}
 		app.home(w, r, form, http.StatusBadRequest)
 		return
+	} else if err := parsedGame.AttachImages(images); err != nil {
+		form.NewGame.Errors = []string{"V balíku chybí některý z obrázků, na které otázky odkazují"}
+		app.home(w, r, form, http.StatusBadRequest)
+		return
+	} else if game, err := app.model.CreateGame(parsedGame, name, author, r.Context()); err == nil {
+		http.Redirect(w, r, editorPath(game), http.StatusSeeOther)
+		return
+	} else {
+		app.serverError(w, err)
+		return
+	}
+}
+
+func (app *application) image(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var imageUid uuid.UUID
+	if uid, err := uuid.Parse(params.ByName("imageUid")); err == nil {
+		imageUid = uid
+	} else {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if image, err := app.model.GetImage(imageUid, r.Context()); err == nil {
+		w.Header().Set("Content-Type", image.Type)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// images are never modified, changing them yields new IDs
+		w.Header().Set("Cache-Control", "max-age=31536000, immutable")
+		w.Write(image.Data)
+	} else if errors.Is(err, model.NoSuchEntity) {
+		app.clientError(w, http.StatusNotFound)
+	} else {
+		app.serverError(w, err)
 	}
 }
 
@@ -335,7 +916,8 @@ func (app *application) showGame(w http.ResponseWriter, r *http.Request, params
 
 	if game, err := app.model.GetGameWithQuestionsAndChoices(gameUid, r.Context()); err == nil {
 		td.Game = game
-		w.Header().Set("Cache-Control", "max-age=3600" /* 1 hour */)
+		// games not played yet are edited in place
+		w.Header().Set("Cache-Control", "no-cache")
 		app.render(w, r, "game-overview.page.tmpl.html", td)
 		return
 	} else if errors.Is(err, model.NoSuchEntity) {
@@ -346,3 +928,333 @@ func (app *application) showGame(w http.ResponseWriter, r *http.Request, params
 		return
 	}
 }
+
+// An item of the quiz editor, either a question with its choices or the start of a round, mirroring rows of the format read by gameCreator.Parse
+type editorItem struct {
+	Round       bool   // the item starts a round
+	Title       string // including the reference to an image, if any
+	Intro       string // of a round
+	Length      string // in milliseconds
+	Type        string // the marker of the question type
+	Parameter   string // the tolerance, margin or number of votes following the marker
+	Category    string
+	Explanation string
+	Choices     []editorChoice // the only choice of a number question holds the correct value
+}
+
+type editorChoice struct {
+	Title    string
+	Correct  bool
+	Feedback string
+}
+
+type editorForm struct {
+	Name   string
+	Author string
+	Items  []editorItem
+	Errors []string
+}
+
+type editorType struct {
+	Marker string // see gameCreator.Parse
+	Name   string
+}
+
+// question types offered by the editor
+var editorTypes = []editorType{
+	{"", "Jedna správná odpověď"},
+	{"multiple", "Více správných odpovědí"},
+	{"text", "Psaná odpověď"},
+	{"number", "Číselný odhad"},
+	{"order", "Seřazení"},
+	{"poll", "Anketa"},
+}
+
+// Reports whether the marker is one of editorTypes, so that a crafted form cannot smuggle in e.g. a round
+func isEditorType(marker string) bool {
+	for _, t := range editorTypes {
+		if t.Marker == marker {
+			return true
+		}
+	}
+	return false
+}
+
+const editorNewChoices = 4 // the number of empty choices of a new question
+
+// Returns the editor items describing the rows of a game
+func newEditorItems(rows [][]string) []editorItem {
+	var items []editorItem
+	for _, row := range rows {
+		if row[0] == "" && len(items) > 0 {
+			items[len(items)-1].Choices = append(items[len(items)-1].Choices, editorChoice{Title: row[1], Correct: row[2] == "1", Feedback: row[4]})
+		} else if row[2] == "round" {
+			items = append(items, editorItem{Round: true, Title: row[0], Intro: row[1]})
+		} else {
+			var marker, parameter = row[2], ""
+			if i := strings.IndexByte(marker, '~'); i >= 0 {
+				marker, parameter = marker[:i], marker[i+1:]
+			}
+			items = append(items, editorItem{Title: row[0], Length: row[1], Type: marker, Parameter: parameter, Category: row[3], Explanation: row[4]})
+		}
+	}
+	return items
+}
+
+// Returns the rows of the item in the format read by gameCreator.Parse
+func (item editorItem) rows() [][]string {
+	if item.Round {
+		return [][]string{{item.Title, item.Intro, "round", "", ""}}
+	}
+	var marker = item.Type
+	if item.Parameter != "" {
+		marker += "~" + item.Parameter
+	}
+	var rows = [][]string{{item.Title, item.Length, marker, item.Category, item.Explanation}}
+	for _, ch := range item.Choices {
+		var correct string
+		if ch.Correct {
+			correct = "1"
+		}
+		rows = append(rows, []string{"", ch.Title, correct, "", ch.Feedback})
+	}
+	return rows
+}
+
+// Reads the editor form, whose fields are named after the positions of items and choices, e.g. i2.c0.title
+func parseEditorForm(form url.Values) editorForm {
+	var f = editorForm{
+		Name:   strings.TrimSpace(form.Get("name")),
+		Author: strings.TrimSpace(form.Get("author")),
+	}
+	for i := 0; ; i++ {
+		var prefix = "i" + strconv.Itoa(i) + "."
+		if _, ok := form[prefix+"title"]; !ok {
+			break
+		}
+		var item = editorItem{
+			Round:       form.Get(prefix+"kind") == "round",
+			Title:       form.Get(prefix + "title"),
+			Intro:       form.Get(prefix + "intro"),
+			Length:      strings.TrimSpace(form.Get(prefix + "length")),
+			Type:        form.Get(prefix + "type"),
+			Parameter:   strings.TrimSpace(form.Get(prefix + "parameter")),
+			Category:    form.Get(prefix + "category"),
+			Explanation: form.Get(prefix + "explanation"),
+		}
+		for j := 0; ; j++ {
+			var choicePrefix = prefix + "c" + strconv.Itoa(j) + "."
+			if _, ok := form[choicePrefix+"title"]; !ok {
+				break
+			}
+			item.Choices = append(item.Choices, editorChoice{
+				Title:    form.Get(choicePrefix + "title"),
+				Correct:  form.Get(choicePrefix+"correct") != "",
+				Feedback: form.Get(choicePrefix + "feedback"),
+			})
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f
+}
+
+// Adds, removes or moves items or choices as requested by the action, e.g. up-2 or remove-choice-2-0.
+// Reports whether the action has been recognised.
+func (f *editorForm) apply(action string) bool {
+	var i, j int
+	var inRange = func(i int) bool { return i >= 0 && i < len(f.Items) }
+	if action == "add-question" {
+		f.Items = append(f.Items, editorItem{Choices: make([]editorChoice, editorNewChoices)})
+	} else if action == "add-round" {
+		f.Items = append(f.Items, editorItem{Round: true})
+	} else if n, _ := fmt.Sscanf(action, "remove-choice-%d-%d", &i, &j); n == 2 && inRange(i) && j >= 0 && j < len(f.Items[i].Choices) {
+		f.Items[i].Choices = append(f.Items[i].Choices[:j], f.Items[i].Choices[j+1:]...)
+	} else if n, _ := fmt.Sscanf(action, "add-choice-%d", &i); n == 1 && inRange(i) {
+		f.Items[i].Choices = append(f.Items[i].Choices, editorChoice{})
+	} else if n, _ := fmt.Sscanf(action, "remove-%d", &i); n == 1 && inRange(i) {
+		f.Items = append(f.Items[:i], f.Items[i+1:]...)
+	} else if n, _ := fmt.Sscanf(action, "up-%d", &i); n == 1 && inRange(i) && i > 0 {
+		f.Items[i-1], f.Items[i] = f.Items[i], f.Items[i-1]
+	} else if n, _ := fmt.Sscanf(action, "down-%d", &i); n == 1 && inRange(i+1) && i >= 0 {
+		f.Items[i], f.Items[i+1] = f.Items[i+1], f.Items[i]
+	} else {
+		return false
+	}
+	return true
+}
+
+// Parses the items of the form the same way as uploaded games, describing the first error found, if any
+func (f editorForm) game() (gameCreator.Game, string) {
+	var parse = func(items []editorItem) (gameCreator.Game, error) {
+		var buf bytes.Buffer
+		var csvW = csv.NewWriter(&buf)
+		for _, item := range items {
+			if err := csvW.WriteAll(item.rows()); err != nil {
+				return gameCreator.Game{}, err
+			}
+		}
+		return gameCreator.Parse(&buf, maxQuestions, maxChoicesPerQuestion)
+	}
+
+	var questions int
+	for i, item := range f.Items {
+		// rows without a title would be taken for choices
+		if strings.TrimSpace(item.Title) == "" {
+			return gameCreator.Game{}, "Položka č. " + strconv.Itoa(i+1) + " nemá nadpis"
+		}
+		if !item.Round {
+			if !isEditorType(item.Type) {
+				return gameCreator.Game{}, "Otázka č. " + strconv.Itoa(i+1) + " má neplatný typ"
+			}
+			questions++
+		}
+	}
+	if questions == 0 {
+		return gameCreator.Game{}, "Kvíz musí obsahovat alespoň jednu otázku"
+	}
+
+	g, err := parse(f.Items)
+	if err == nil {
+		return g, ""
+	} else if errors.Is(err, gameCreator.ErrTooManyQuestions) {
+		return g, "Kvíz může obsahovat nejvýše " + strconv.Itoa(maxQuestions) + " otázek"
+	}
+	// find the first question at fault, rounds are not complete without their questions
+	for i := range f.Items {
+		if f.Items[i].Round {
+			continue
+		}
+		if _, err := parse(f.Items[:i+1]); errors.Is(err, gameCreator.ErrTooManyChoices) {
+			return g, "Otázka č. " + strconv.Itoa(i+1) + " může mít nejvýše " + strconv.Itoa(maxChoicesPerQuestion) + " odpovědí"
+		} else if err != nil {
+			return g, "Položka č. " + strconv.Itoa(i+1) + " není v pořádku"
+		}
+	}
+	return g, "Každé kolo musí obsahovat alespoň jednu otázku"
+}
+
+// Returns the secret path of the editor of the game, which is the only way to edit it
+func editorPath(game *ent.Game) string {
+	return "/quiz/" + url.PathEscape(game.ID.String()) + "/edit/" + url.PathEscape(game.EditToken.String())
+}
+
+func (app *application) editor(w http.ResponseWriter, r *http.Request, game *ent.Game, images []string, form editorForm, status int) {
+	type editorData struct {
+		Game   *ent.Game
+		Images []string // names of images the titles may refer to
+		Types  []editorType
+		Form   editorForm
+		Saved  bool
+		templateData
+	}
+	_, saved := r.URL.Query()["saved"]
+	td := &editorData{
+		Game:   game,
+		Images: images,
+		Types:  editorTypes,
+		Form:   form,
+		Saved:  saved && status == http.StatusOK,
+	}
+	setDefaultTemplateData(&td.templateData)
+	w.WriteHeader(status)
+	app.render(w, r, "editor.page.tmpl.html", td)
+}
+
+// Loads the game to be edited, responding with an error unless it may be edited
+func (app *application) loadEditedGame(w http.ResponseWriter, r *http.Request, params httprouter.Params) (gameCreator.Game, *ent.Game, []string, bool) {
+	var gameUid uuid.UUID
+	if uid, err := uuid.Parse(params.ByName("gameUid")); err == nil {
+		gameUid = uid
+	} else {
+		app.clientError(w, http.StatusBadRequest)
+		return gameCreator.Game{}, nil, nil, false
+	}
+	var editToken uuid.UUID
+	if token, err := uuid.Parse(params.ByName("editToken")); err == nil {
+		editToken = token
+	} else {
+		app.clientError(w, http.StatusNotFound)
+		return gameCreator.Game{}, nil, nil, false
+	}
+
+	if exported, game, err := app.model.ExportGame(gameUid, editToken, r.Context()); err == nil {
+		// only the latest version may be edited, all versions share the token
+		if game.Edges.Next != nil {
+			game.Edges.Next.EditToken = game.EditToken
+			http.Redirect(w, r, editorPath(game.Edges.Next), http.StatusSeeOther)
+			return gameCreator.Game{}, nil, nil, false
+		}
+		var images = make([]string, 0, len(exported.Images))
+		for name := range exported.Images {
+			images = append(images, name)
+		}
+		sort.Strings(images)
+		return exported, game, images, true
+	} else if errors.Is(err, model.NoSuchEntity) || errors.Is(err, model.Forbidden) {
+		// a wrong token does not reveal the game exists
+		app.clientError(w, http.StatusNotFound)
+		return gameCreator.Game{}, nil, nil, false
+	} else {
+		app.serverError(w, err)
+		return gameCreator.Game{}, nil, nil, false
+	}
+}
+
+func (app *application) editGame(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	exported, game, images, ok := app.loadEditedGame(w, r, params)
+	if !ok {
+		return
+	}
+	var form = editorForm{
+		Name:   game.Name,
+		Author: game.Author,
+		Items:  newEditorItems(gameCreator.Rows(exported)),
+	}
+	app.editor(w, r, game, images, form, http.StatusOK)
+}
+
+func (app *application) saveGame(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	exported, game, images, ok := app.loadEditedGame(w, r, params)
+	if !ok {
+		return
+	}
+
+	var form = parseEditorForm(r.PostForm)
+	if action := r.PostForm.Get("action"); action != "save" {
+		if !form.apply(action) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		app.editor(w, r, game, images, form, http.StatusOK)
+		return
+	}
+
+	if form.Name == "" || len(form.Name) > 64 || form.Author == "" || len(form.Author) > 64 {
+		form.Errors = []string{"Zadejte název kvízu a jméno autora, každé nejvýše o 64 znacích"}
+		app.editor(w, r, game, images, form, http.StatusBadRequest)
+		return
+	}
+	if edited, msg := form.game(); msg != "" {
+		form.Errors = []string{msg}
+		app.editor(w, r, game, images, form, http.StatusBadRequest)
+		return
+	} else if err := edited.AttachImages(exported.Images); err != nil {
+		form.Errors = []string{"Kvíz neobsahuje některý z obrázků, na které otázky odkazují"}
+		app.editor(w, r, game, images, form, http.StatusBadRequest)
+		return
+	} else if updated, err := app.model.UpdateGame(game.ID, game.EditToken, edited, form.Name, form.Author, r.Context()); err == nil {
+		http.Redirect(w, r, editorPath(updated)+"?saved", http.StatusSeeOther)
+		return
+	} else if errors.Is(err, model.Outdated) {
+		form.Errors = []string{"Kvíz mezitím upravil někdo jiný, otevřete jeho nejnovější verzi"}
+		app.editor(w, r, game, images, form, http.StatusConflict)
+		return
+	} else {
+		app.serverError(w, err)
+		return
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
@@ -15,12 +16,19 @@ import (
 	"runtime/debug"
 	"strings"
 	"time"
+	"vkane.cz/tinyquiz/pkg/markdown"
 	"vkane.cz/tinyquiz/pkg/model"
 	"vkane.cz/tinyquiz/pkg/model/ent"
 	"vkane.cz/tinyquiz/pkg/rtcomm"
 	"vkane.cz/tinyquiz/ui"
 )
 
+var templateFuncs = template.FuncMap{
+	"imageURL": model.ImageURL,
+	"markdown": markdown.Render,
+	"inc":      func(i int) int { return i + 1 }, // for numbering from one
+}
+
 func newTemplateCache() (map[string]*template.Template, error) {
 	cache := map[string]*template.Template{}
 
@@ -37,7 +45,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	for _, page := range pages {
 		name := filepath.Base(page)
 
-		ts, err := template.ParseFS(templates, page)
+		ts, err := template.New(name).Funcs(templateFuncs).ParseFS(templates, page)
 		if err != nil {
 			return nil, err
 		}
@@ -92,6 +100,47 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+const automationTimeout = 10 * time.Second
+const automationRetry = time.Second // the delay before the first retry after a failure, doubled for each further one
+const automationRetries = 5
+
+// Lets the model advance the session on its own, notifies the clients if it did and plans the next attempt.
+// Runs outside of any request, therefore errors are only logged and the attempt is retried a few times with growing delays.
+func (app *application) autoAdvance(sessionId uuid.UUID) {
+	app.retryAutoAdvance(sessionId, 0)
+}
+
+// Same as autoAdvance, failed is the number of attempts that failed in a row before this one
+func (app *application) retryAutoAdvance(sessionId uuid.UUID, failed int) {
+	c, cancel := context.WithTimeout(context.Background(), automationTimeout)
+	defer cancel()
+
+	var now = time.Now()
+	advanced, next, err := app.model.AutoAdvance(sessionId, now, c)
+	if err != nil {
+		// a removed session is gone for good
+		if errors.Is(err, model.NoSuchEntity) || failed >= automationRetries {
+			app.errorLog.Printf("advancing session %s automatically failed with %v, giving up\n", sessionId.String(), err)
+		} else {
+			app.errorLog.Printf("advancing session %s automatically failed with %v, retrying\n", sessionId.String(), err)
+			app.scheduler.Schedule(sessionId, now.Add(automationRetry<<failed), func() { app.retryAutoAdvance(sessionId, failed+1) })
+		}
+		return
+	}
+	if advanced {
+		if su, err := app.model.GetQuestionStateUpdate(sessionId, now, c); err == nil {
+			app.rtClients.SendToAll(sessionId, su)
+		} else {
+			app.errorLog.Printf("failed getting StateUpdate for session %s with %v\n", sessionId.String(), err)
+		}
+	}
+	if !next.IsZero() {
+		app.scheduler.Schedule(sessionId, next, func() { app.autoAdvance(sessionId) })
+	} else {
+		app.scheduler.Cancel(sessionId)
+	}
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:    1, // we will not be reading from the socket
 	EnableCompression: true,
@@ -111,8 +160,11 @@ func (app *application) processWebSocket(w http.ResponseWriter, r *http.Request,
 	}
 
 	var player *ent.Player
-	if p, err := app.model.GetPlayerWithSessionAndGame(playerUid, r.Context()); err == nil {
+	if p, err := app.model.GetPlayerWithSessionAndGame(playerUid, r.Context()); err == nil && p.Kicked == nil {
 		player = p
+	} else if err == nil {
+		app.clientError(w, http.StatusForbidden)
+		return
 	} else if errors.Is(err, model.NoSuchEntity) {
 		app.clientError(w, http.StatusNotFound)
 		return
@@ -135,6 +187,11 @@ func (app *application) processWebSocket(w http.ResponseWriter, r *http.Request,
 		var ch = make(chan rtcomm.StateUpdate, suBufferSize)
 		app.rtClients.AddClient(player.Edges.Session.ID, ch)
 		defer app.rtClients.RemoveClient(player.Edges.Session.ID, ch)
+		// players of self-paced sessions also get updates sent to them only
+		if player.Edges.Session.SelfPaced {
+			app.rtClients.AddClient(playerUid, ch)
+			defer app.rtClients.RemoveClient(playerUid, ch)
+		}
 		if su, err := app.model.GetFullStateUpdate(player.Edges.Session.ID, time.Now(), r.Context()); err == nil {
 			select {
 			case ch <- su:
@@ -145,6 +202,18 @@ func (app *application) processWebSocket(w http.ResponseWriter, r *http.Request,
 		} else {
 			app.errorLog.Printf("failed getting initial StateUpdate for %s with %v\n", playerUid.String(), err)
 		}
+		if player.Edges.Session.SelfPaced && !player.Organiser {
+			if su, err := app.model.GetOwnQuestionStateUpdate(playerUid, time.Now(), r.Context()); err == nil {
+				select {
+				case ch <- su:
+					break
+				default:
+					app.infoLog.Printf("could not send initial own StateUpdate to %s\n", playerUid.String())
+				}
+			} else {
+				app.errorLog.Printf("failed getting initial own StateUpdate for %s with %v\n", playerUid.String(), err)
+			}
+		}
 		var gcTicker = time.Tick(socketGCPeriod)
 		var devnull [0]byte
 	loop:
@@ -161,12 +230,16 @@ func (app *application) processWebSocket(w http.ResponseWriter, r *http.Request,
 					app.errorLog.Printf("setting write deadline for %s failed with %v\n", playerUid.String(), err)
 					break loop
 				}
-				if err := c.WriteJSON(su); errors.Is(err, io.EOF) {
+				var personalised = su.Personalise(player.Name, player.Organiser)
+				if err := c.WriteJSON(personalised); errors.Is(err, io.EOF) {
 					break loop
 				} else if err != nil {
 					app.infoLog.Printf("sending message for %s failed with %v\n", playerUid.String(), err)
 					break loop
 				}
+				if personalised.Removed {
+					break loop
+				}
 			}
 		}
 	}
@@ -15,6 +15,7 @@ import (
 	"vkane.cz/tinyquiz/pkg/model/ent"
 	"vkane.cz/tinyquiz/pkg/model/ent/migrate"
 	rtcomm "vkane.cz/tinyquiz/pkg/rtcomm"
+	"vkane.cz/tinyquiz/pkg/scheduler"
 	"vkane.cz/tinyquiz/ui"
 
 	"github.com/julienschmidt/httprouter"
@@ -27,6 +28,7 @@ type application struct {
 	templateCache map[string]*template.Template
 	model         *model.Model
 	rtClients     *rtcomm.Clients
+	scheduler     *scheduler.Scheduler
 }
 
 type templateData struct {
@@ -83,6 +85,7 @@ func main() {
 		errorLog:  errorLog,
 		infoLog:   infoLog,
 		rtClients: rtcomm.NewClients(),
+		scheduler: scheduler.NewScheduler(),
 	}
 
 	if tc, err := newTemplateCache(); err == nil {
@@ -96,6 +99,40 @@ func main() {
 			errorLog.Fatal(err)
 		}
 		app.model = model.NewModel(c)
+		// answers saved before they referred to asked questions are linked by a one-off run of the binary
+		if _, ok := os.LookupEnv("TINYQUIZ_LINK_ANSWERS"); ok {
+			if linked, err := app.model.LinkAnswers(context.Background()); err == nil {
+				infoLog.Printf("Linked %d answers to asked questions\n", linked)
+				return
+			} else {
+				errorLog.Fatal(err)
+			}
+		} else if unlinked, err := app.model.HasUnlinkedAnswers(context.Background()); err != nil {
+			errorLog.Fatal(err)
+		} else if unlinked {
+			errorLog.Fatal("answers saved by an older version are not linked to asked questions, run once with TINYQUIZ_LINK_ANSWERS set")
+		}
+		// games created before edit tokens existed are made editable by a one-off run of the binary as well
+		if _, ok := os.LookupEnv("TINYQUIZ_ISSUE_EDIT_TOKENS"); ok {
+			if games, err := app.model.IssueEditTokens(context.Background()); err == nil {
+				for _, g := range games {
+					infoLog.Printf("Editor of %s (%s) by %s: %s\n", g.Name, g.Code, g.Author, editorPath(g))
+				}
+				infoLog.Printf("Issued edit tokens to %d games\n", len(games))
+				return
+			} else {
+				errorLog.Fatal(err)
+			}
+		}
+	} else {
+		errorLog.Fatal(err)
+	}
+
+	// resume automation of sessions interrupted by a restart
+	if sessions, err := app.model.GetAutomatedSessions(context.Background()); err == nil {
+		for _, s := range sessions {
+			app.autoAdvance(s)
+		}
 	} else {
 		errorLog.Fatal(err)
 	}
@@ -114,11 +151,25 @@ func main() {
 	mux.POST("/session", app.createSession)
 	mux.GET("/game/:playerUid", app.game)
 	mux.POST("/game/:playerUid/rpc/next", app.nextQuestion)
+	mux.POST("/game/:playerUid/rpc/skip", app.skipQuestion)
+	mux.POST("/game/:playerUid/rpc/repeat", app.repeatQuestion)
+	mux.POST("/game/:playerUid/rpc/ask/:questionUid", app.askQuestion)
+	mux.POST("/game/:playerUid/rpc/pause", app.pauseQuestion)
+	mux.POST("/game/:playerUid/rpc/resume", app.resumeQuestion)
+	mux.POST("/game/:playerUid/rpc/extend", app.extendQuestion)
+	mux.POST("/game/:playerUid/rpc/kick", app.kickPlayer)
+	mux.POST("/game/:playerUid/rpc/accept", app.acceptAnswer)
+	mux.POST("/game/:playerUid/rpc/finish", app.finishSession)
+	mux.POST("/game/:playerUid/next", app.nextOwnQuestion)
 	mux.POST("/game/:playerUid/answers/:choiceUid", app.answer)
+	mux.POST("/game/:playerUid/answers", app.answerSet)
 	mux.GET("/results/:playerUid", app.resultsGeneral)
 	mux.GET("/template", app.downloadTemplate)
 	mux.POST("/game", app.createGame)
 	mux.GET("/quiz/:gameUid", app.showGame)
+	mux.GET("/quiz/:gameUid/edit/:editToken", app.editGame)
+	mux.POST("/quiz/:gameUid/edit/:editToken", app.saveGame)
+	mux.GET("/image/:imageUid", app.image)
 	mux.GET("/help", app.help)
 
 	mux.GET("/ws/:playerUid", app.processWebSocket)
@@ -1,10 +1,16 @@
 package gameCreator
 
 import (
+	"archive/zip"
 	"encoding/csv"
 	"errors"
 	"io"
+	"math"
+	"net/http"
+	"path"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -38,53 +44,214 @@ func CreateTemplate(w io.Writer, questions uint64, choicesPerQuestion uint64) (r
 	return nil
 }
 
+// Returns the rows describing the game g in the format read by Parse, images are referenced by their names
+func Rows(g Game) [][]string {
+	var rows [][]string
+	var round int
+	for _, q := range g.Questions {
+		// round rows precede the first question of their round
+		if q.Round != round && q.Round > 0 && q.Round <= len(g.Rounds) {
+			round = q.Round
+			rows = append(rows, []string{g.Rounds[round-1].Title, g.Rounds[round-1].Intro, roundMarker, "", ""})
+		}
+		var marker string
+		for m, t := range questionTypeMarkers {
+			if t == q.Type {
+				marker = m
+			}
+		}
+		if q.Type == Text && q.Tolerance > 0 {
+			marker += "~" + strconv.FormatUint(uint64(q.Tolerance), 10)
+		} else if q.Type == Number && q.Margin > 0 {
+			marker += "~" + strconv.FormatFloat(q.Margin, 'f', -1, 64)
+		} else if q.Type == Poll && q.Votes > 1 {
+			marker += "~" + strconv.FormatUint(uint64(q.Votes), 10)
+		}
+		rows = append(rows, []string{joinImage(q.Title, q.Image), strconv.FormatUint(q.Length, 10), marker, q.Category, q.Explanation})
+		if q.Type == Number {
+			rows = append(rows, []string{"", strconv.FormatFloat(q.Value, 'f', -1, 64), "", "", ""})
+		}
+		for _, ch := range q.Choices {
+			var correct string
+			if ch.Correct {
+				correct = "1"
+			}
+			rows = append(rows, []string{"", joinImage(ch.Title, ch.Image), correct, "", ch.Feedback})
+		}
+	}
+	return rows
+}
+
 type Game struct {
 	Questions []Question
+	Rounds    []Round
+	Images    map[string]Image // keyed by name, see AttachImages
+}
+
+// A round groups the questions following its row up to the next round
+type Round struct {
+	Title string
+	Intro string // shown on the title slide of the round, may be empty
 }
 
 type Question struct {
-	Title   string
-	Choices []Choice
-	Length  uint64
+	Title     string
+	Choices   []Choice
+	Length    uint64
+	Type      QuestionType
+	Tolerance uint8   // typos forgiven in answers to text questions, see textMatch.Matches
+	Value     float64 // the correct answer to a number question
+	Margin    float64 // the distance from Value at which guesses to number questions stop scoring, zero if the closest guesses win
+	Votes     uint8   // the number of choices a player may vote for in a poll
+	Image     string  // the name of the image shown with the question, empty if none
+	Category  string  // sessions may draw their questions evenly from categories, empty if none
+	Round     int     // the index of the round in Game.Rounds plus one, zero if the question belongs to no round
+	// shown once the question closes, e.g. why the correct answer is correct, empty if none
+	Explanation string
+}
+
+type QuestionType uint8
+
+const (
+	SingleChoice   QuestionType = iota // the player picks one choice
+	MultipleChoice                     // the player picks a set of choices and submits it at once
+	Text                               // the player types the answer, the choices are the accepted answers
+	Number                             // the player guesses a number, the only choice row holds the correct value instead of a choice
+	Ordering                           // the player puts the choices, which are listed in the correct order, in order
+	Poll                               // the player votes for choices none of which is correct, the question is not scored
+)
+
+// markers of question types in the third column of question rows
+// The text marker may be followed by a tilde and the tolerance, e.g. text~1, the number marker by the margin, e.g. number~10,
+// and the poll marker by the number of votes per player, e.g. poll~2.
+var questionTypeMarkers = map[string]QuestionType{
+	"":         SingleChoice,
+	"multiple": MultipleChoice,
+	"text":     Text,
+	"number":   Number,
+	"order":    Ordering,
+	"poll":     Poll,
 }
 
+const MaxTolerance = 3
+
+const maxImageNameLength = 255
+
+const maxCategoryLength = 255
+
+// the marker of round rows in the third column, their second column holds the intro of the round
+const roundMarker = "round"
+
+const maxRoundTitleLength = 256
+const maxRoundIntroLength = 1024
+
+const maxExplanationLength = 1024
+const maxFeedbackLength = 512
+
 type Choice struct {
-	Title   string
-	Correct bool
+	Title    string // may be empty if there is an image
+	Correct  bool
+	Image    string
+	Feedback string // shown to players who picked the choice once the question closes, empty if none
+}
+
+type Image struct {
+	Type string // MIME type
+	Data []byte
+}
+
+// a reference to an image in a title, written like an image in Markdown: ![description](name)
+var imageReference = regexp.MustCompile(`!\[[^\]]*\]\(([^)]+)\)`)
+
+// image types accepted in bundles, SVG is left out as it may carry scripts
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
 }
 
 var ErrTooManyQuestions = errors.New("there were questions above the limit")
 var ErrTooManyChoices = errors.New("there were choices above the limit")
 var ErrInvalidSyntax = errors.New("")
+var ErrTooManyImages = errors.New("there were images above the limit")
+var ErrInvalidImage = errors.New("an image is too large or of an unsupported type")
+var ErrImagesTooLarge = errors.New("the images together are above the size limit")
+var ErrMissingImage = errors.New("a referenced image is missing")
 
 func Parse(r io.Reader, maxQuestions uint64, maxChoicesPerQuestion uint64) (Game, error) {
 	var g Game
 	var csvR = csv.NewReader(r)
-	csvR.FieldsPerRecord = 3
+	// the fourth column holding categories of questions and the fifth one holding explanations of questions
+	// and feedback on choices are optional
+	csvR.FieldsPerRecord = -1
 	csvR.TrimLeadingSpace = true
 	var questions, choices uint64
+	var roundQuestions int // questions of the last round so far
 	for {
 		if row, err := csvR.Read(); err == nil {
-			if row[0] == "" && row[1] == "" && row[2] == "" {
+			var category, note string // the note is the explanation of a question or the feedback on a choice
+			if len(row) < 3 || len(row) > 5 {
+				return g, ErrInvalidSyntax
+			}
+			if len(row) >= 4 {
+				category = strings.TrimSpace(row[3])
+			}
+			if len(row) == 5 {
+				note = strings.TrimSpace(row[4])
+			}
+			if row[0] == "" && row[1] == "" && row[2] == "" && category == "" && note == "" {
 				continue
 			} else if row[0] == "" {
 				choices++
-				if questions == 0 {
+				if questions == 0 || category != "" || len(g.Rounds) > 0 && roundQuestions == 0 {
+					return g, ErrInvalidSyntax
+				}
+				// feedback is given on picked choices only
+				if t := g.Questions[len(g.Questions)-1].Type; note != "" && (t == Text || t == Number || t == Ordering) || len(note) > maxFeedbackLength {
 					return g, ErrInvalidSyntax
 				}
 				if choices > maxChoicesPerQuestion {
 					return g, ErrTooManyChoices
 				}
+				if q := &g.Questions[len(g.Questions)-1]; q.Type == Number {
+					if v, err := ParseNumber(row[1]); err == nil && choices == 1 {
+						q.Value = v
+						continue
+					} else {
+						return g, ErrInvalidSyntax
+					}
+				}
 				var correct bool
-				if row[2] == "1" {
+				if t := g.Questions[len(g.Questions)-1].Type; (row[2] == "1" && t != Poll) || t == Text {
 					correct = true
 				}
+				var title, image = splitImage(row[1])
+				if title == "" && image == "" {
+					return g, ErrInvalidSyntax
+				}
 				g.Questions[len(g.Questions)-1].Choices = append(g.Questions[len(g.Questions)-1].Choices, Choice{
-					Title:   row[1],
-					Correct: correct,
+					Title:    title,
+					Correct:  correct,
+					Image:    image,
+					Feedback: note,
 				})
+			} else if row[2] == roundMarker {
+				if questions > 0 && !complete(g.Questions[len(g.Questions)-1], choices) || len(g.Rounds) > 0 && roundQuestions == 0 {
+					return g, ErrInvalidSyntax
+				}
+				var round = Round{Title: strings.TrimSpace(row[0]), Intro: strings.TrimSpace(row[1])}
+				if category != "" || note != "" || round.Title == "" || len(round.Title) > maxRoundTitleLength || len(round.Intro) > maxRoundIntroLength {
+					return g, ErrInvalidSyntax
+				}
+				g.Rounds = append(g.Rounds, round)
+				roundQuestions = 0
 			} else {
+				if questions > 0 && !complete(g.Questions[len(g.Questions)-1], choices) {
+					return g, ErrInvalidSyntax
+				}
 				questions++
+				roundQuestions++
 				choices = 0
 				if questions > maxQuestions {
 					return g, ErrTooManyQuestions
@@ -103,12 +270,47 @@ func Parse(r io.Reader, maxQuestions uint64, maxChoicesPerQuestion uint64) (Game
 						length = uint64((10 * time.Second).Milliseconds())
 					}
 				}
-				g.Questions = append(g.Questions, Question{
-					Title:  row[0],
-					Length: length,
-				})
+				var marker, tolerance = row[2], ""
+				if i := strings.IndexByte(marker, '~'); i >= 0 {
+					marker, tolerance = marker[:i], marker[i+1:]
+				}
+				if len(category) > maxCategoryLength || len(note) > maxExplanationLength {
+					return g, ErrInvalidSyntax
+				}
+				var q = Question{
+					Length:      length,
+					Category:    category,
+					Round:       len(g.Rounds),
+					Explanation: note,
+				}
+				if q.Title, q.Image = splitImage(row[0]); q.Title == "" {
+					return g, ErrInvalidSyntax
+				}
+				if t, ok := questionTypeMarkers[marker]; ok {
+					q.Type = t
+				} else {
+					return g, ErrInvalidSyntax
+				}
+				if tolerance != "" {
+					if t, err := strconv.ParseUint(tolerance, 10, 8); err == nil && t <= MaxTolerance && q.Type == Text {
+						q.Tolerance = uint8(t)
+					} else if m, err := ParseNumber(tolerance); err == nil && m >= 0 && q.Type == Number {
+						q.Margin = m
+					} else if v, err := strconv.ParseUint(tolerance, 10, 8); err == nil && v >= 1 && q.Type == Poll {
+						q.Votes = uint8(v)
+					} else {
+						return g, ErrInvalidSyntax
+					}
+				}
+				if q.Type == Poll && q.Votes == 0 {
+					q.Votes = 1
+				}
+				g.Questions = append(g.Questions, q)
 			}
 		} else if err == io.EOF {
+			if questions > 0 && !complete(g.Questions[len(g.Questions)-1], choices) || len(g.Rounds) > 0 && roundQuestions == 0 {
+				return g, ErrInvalidSyntax
+			}
 			break
 		} else if err == csv.ErrFieldCount {
 			return g, ErrInvalidSyntax
@@ -118,3 +320,121 @@ func Parse(r io.Reader, maxQuestions uint64, maxChoicesPerQuestion uint64) (Game
 	}
 	return g, nil
 }
+
+// Reports whether the question q followed by the given number of choice rows has all it needs
+func complete(q Question, choiceRows uint64) bool {
+	switch q.Type {
+	case Number:
+		return choiceRows == 1
+	case Ordering:
+		return len(q.Choices) >= 2
+	case Poll:
+		return len(q.Choices) >= 2 && int(q.Votes) <= len(q.Choices)
+	default:
+		return true
+	}
+}
+
+// Parses a finite number written either with a decimal point or a decimal comma
+func ParseNumber(s string) (float64, error) {
+	if f, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64); err != nil {
+		return 0, err
+	} else if math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, ErrInvalidSyntax
+	} else {
+		return f, nil
+	}
+}
+
+// Splits an optional reference to an image off the title, see imageReference
+func splitImage(title string) (string, string) {
+	if m := imageReference.FindStringSubmatchIndex(title); m != nil {
+		return strings.TrimSpace(title[:m[0]] + title[m[1]:]), strings.TrimSpace(title[m[2]:m[3]])
+	}
+	return title, ""
+}
+
+// Appends a reference to the image of the given name to the title, see splitImage
+func joinImage(title string, image string) string {
+	if image == "" {
+		return title
+	}
+	return strings.TrimSpace(title + " ![](" + image + ")")
+}
+
+// Reads images from a zip bundle of the given size, keyed by their file names without directories.
+// Reading stops as soon as the uncompressed images exceed maxTotalSize together, so that a small bundle cannot exhaust memory.
+func ReadImages(r io.ReaderAt, size int64, maxImages uint64, maxImageSize uint64, maxTotalSize uint64) (map[string]Image, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrInvalidSyntax
+	}
+	var images = make(map[string]Image)
+	var total uint64
+	for _, f := range zr.File {
+		var name = path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if _, ok := images[name]; ok {
+			return nil, ErrInvalidSyntax
+		}
+		if uint64(len(images)) >= maxImages {
+			return nil, ErrTooManyImages
+		}
+		if f.UncompressedSize64 > maxImageSize || len(name) > maxImageNameLength {
+			return nil, ErrInvalidImage
+		}
+		if total+f.UncompressedSize64 > maxTotalSize {
+			return nil, ErrImagesTooLarge
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, ErrInvalidSyntax
+		}
+		// the declared size is not to be trusted
+		data, err := io.ReadAll(io.LimitReader(rc, int64(maxImageSize)+1))
+		rc.Close()
+		if err != nil {
+			return nil, ErrInvalidSyntax
+		} else if uint64(len(data)) > maxImageSize {
+			return nil, ErrInvalidImage
+		}
+		total += uint64(len(data))
+		if total > maxTotalSize {
+			return nil, ErrImagesTooLarge
+		}
+		var t = http.DetectContentType(data)
+		if !imageTypes[t] {
+			return nil, ErrInvalidImage
+		}
+		images[name] = Image{Type: t, Data: data}
+	}
+	return images, nil
+}
+
+// Keeps those of images, which are referenced by the questions and choices of g, all of which must be present
+func (g *Game) AttachImages(images map[string]Image) error {
+	g.Images = make(map[string]Image)
+	var attach = func(name string) error {
+		if name == "" {
+			return nil
+		} else if image, ok := images[name]; ok {
+			g.Images[name] = image
+			return nil
+		} else {
+			return ErrMissingImage
+		}
+	}
+	for _, q := range g.Questions {
+		if err := attach(q.Image); err != nil {
+			return err
+		}
+		for _, ch := range q.Choices {
+			if err := attach(ch.Image); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
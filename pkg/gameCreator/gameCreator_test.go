@@ -1,7 +1,9 @@
 package gameCreator
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/csv"
 	"reflect"
 	"strings"
 	"testing"
@@ -64,3 +66,261 @@ func TestParse(t *testing.T) {
 		t.Fatalf("Unexpected error from Parse: %v", err)
 	}
 }
+
+func TestParse_multipleChoice(t *testing.T) {
+	const input = "Prime numbers are,3000,multiple\n,2,1\n,4,\n,7,1\n"
+	if g, err := Parse(strings.NewReader(input), 10, 10); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	} else if len(g.Questions) != 1 || g.Questions[0].Type != MultipleChoice {
+		t.Fatalf("Expected a multiple-choice question, got %#v", g)
+	}
+
+	if _, err := Parse(strings.NewReader("Prime numbers are,3000,several\n,2,1\n"), 10, 10); err != ErrInvalidSyntax {
+		t.Fatalf("Expected an unknown question type to be refused, got: %v", err)
+	}
+}
+
+func TestParse_text(t *testing.T) {
+	const input = "Capital of Australia,3000,text~1\n,Canberra,\n,Canbera,\n"
+	if g, err := Parse(strings.NewReader(input), 10, 10); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	} else if len(g.Questions) != 1 || g.Questions[0].Type != Text || g.Questions[0].Tolerance != 1 {
+		t.Fatalf("Expected a text question with tolerance 1, got %#v", g)
+	} else if len(g.Questions[0].Choices) != 2 || !g.Questions[0].Choices[0].Correct || !g.Questions[0].Choices[1].Correct {
+		t.Fatalf("Expected both choices to be accepted answers, got %#v", g.Questions[0].Choices)
+	}
+
+	for _, marker := range []string{"text~4", "text~x", "multiple~1"} {
+		if _, err := Parse(strings.NewReader("Capital of Australia,3000,"+marker+"\n,Canberra,\n"), 10, 10); err != ErrInvalidSyntax {
+			t.Errorf("Expected the marker %s to be refused, got: %v", marker, err)
+		}
+	}
+}
+
+func TestParse_number(t *testing.T) {
+	const input = "Bones in the human body,3000,number~10\n,206,\nPi,,number\n,\"3,14\",\n"
+	if g, err := Parse(strings.NewReader(input), 10, 10); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	} else if len(g.Questions) != 2 || g.Questions[0].Type != Number || g.Questions[0].Value != 206 || g.Questions[0].Margin != 10 || len(g.Questions[0].Choices) != 0 {
+		t.Fatalf("Expected a number question with value 206 and margin 10, got %#v", g)
+	} else if g.Questions[1].Value != 3.14 || g.Questions[1].Margin != 0 {
+		t.Fatalf("Expected a number question with value 3.14 and no margin, got %#v", g.Questions[1])
+	}
+
+	for _, input := range []string{
+		"Bones,3000,number\n",                // no value
+		"Bones,3000,number\n,206,\n,207,\n",  // two values
+		"Bones,3000,number\n,many,\n",        // not a number
+		"Bones,3000,number~-1\n,206,\n",      // negative margin
+		"Bones,3000,number\nPi,,number\n,3,", // the first question has no value
+	} {
+		if _, err := Parse(strings.NewReader(input), 10, 10); err != ErrInvalidSyntax {
+			t.Errorf("Expected %q to be refused, got: %v", input, err)
+		}
+	}
+}
+
+func TestParse_ordering(t *testing.T) {
+	const input = "Order by date,3000,order\n,Battle of Hastings,\n,Magna Carta,\n,Battle of Agincourt,\n"
+	if g, err := Parse(strings.NewReader(input), 10, 10); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	} else if len(g.Questions) != 1 || g.Questions[0].Type != Ordering || len(g.Questions[0].Choices) != 3 || g.Questions[0].Choices[1].Title != "Magna Carta" {
+		t.Fatalf("Expected an ordering question with 3 choices in the given order, got %#v", g)
+	}
+
+	if _, err := Parse(strings.NewReader("Order by date,3000,order\n,Battle of Hastings,\n"), 10, 10); err != ErrInvalidSyntax {
+		t.Fatalf("Expected an ordering question with a single choice to be refused, got: %v", err)
+	}
+}
+
+func TestParse_poll(t *testing.T) {
+	const input = "Favourite season,,poll\n,Spring,1\n,Summer,\n,Autumn,\nBest fruits,,poll~2\n,Apples,\n,Pears,\n,Plums,\n"
+	if g, err := Parse(strings.NewReader(input), 10, 10); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	} else if len(g.Questions) != 2 || g.Questions[0].Type != Poll || g.Questions[0].Votes != 1 || g.Questions[1].Votes != 2 {
+		t.Fatalf("Expected two polls allowing 1 and 2 votes, got %#v", g)
+	} else if g.Questions[0].Choices[0].Correct {
+		t.Fatalf("Expected no choice of a poll to be correct, got %#v", g.Questions[0].Choices)
+	}
+
+	var invalid = []string{
+		"Favourite season,,poll\n,Spring,\n",
+		"Favourite season,,poll~3\n,Spring,\n,Summer,\n",
+		"Favourite season,,poll~0\n,Spring,\n,Summer,\n",
+	}
+	for _, input := range invalid {
+		if _, err := Parse(strings.NewReader(input), 10, 10); err != ErrInvalidSyntax {
+			t.Errorf("Expected %q to be refused, got: %v", input, err)
+		}
+	}
+}
+
+func TestParse_categories(t *testing.T) {
+	const input = "H2O is,3000,,Chemistry\n,Water,1,\n,Salt,,\nπ is rational,,\n,Yes,\n,No,1\n"
+	if g, err := Parse(strings.NewReader(input), 10, 10); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	} else if len(g.Questions) != 2 || g.Questions[0].Category != "Chemistry" || g.Questions[1].Category != "" || len(g.Questions[0].Choices) != 2 {
+		t.Fatalf("Expected the first question only to have a category, got %#v", g)
+	}
+
+	var invalid = []string{
+		"H2O is,3000,,Chemistry\n,Water,1,Physics\n",
+		"H2O is,3000,,Chemistry,,\n,Water,1\n",
+		"H2O is,3000\n,Water,1\n",
+	}
+	for _, input := range invalid {
+		if _, err := Parse(strings.NewReader(input), 10, 10); err != ErrInvalidSyntax {
+			t.Errorf("Expected %q to be refused, got: %v", input, err)
+		}
+	}
+}
+
+func TestParse_explanations(t *testing.T) {
+	const input = "H2O is,3000,,,Two hydrogens and one oxygen.\n,Water,1,,\n,Salt,,,Salt is NaCl.\nHow many legs has a spider?,,number\n,8,\n"
+	g, err := Parse(strings.NewReader(input), 10, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	if g.Questions[0].Explanation != "Two hydrogens and one oxygen." || g.Questions[1].Explanation != "" {
+		t.Errorf("Expected the first question only to have an explanation, got %#v", g.Questions)
+	}
+	if g.Questions[0].Choices[0].Feedback != "" || g.Questions[0].Choices[1].Feedback != "Salt is NaCl." {
+		t.Errorf("Expected the second choice only to have feedback, got %#v", g.Questions[0].Choices)
+	}
+
+	var invalid = []string{
+		"How many legs has a spider?,,number\n,8,,,Count them.\n",
+		"Capital of France?,,text\n,Paris,,,Right.\n",
+		"History,,round,,Dates\nWho wrote Carmen?,,\n,Bizet,1\n",
+		"H2O is,3000,,," + strings.Repeat("x", maxExplanationLength+1) + "\n,Water,1\n",
+	}
+	for _, input := range invalid {
+		if _, err := Parse(strings.NewReader(input), 10, 10); err != ErrInvalidSyntax {
+			t.Errorf("Expected %.40q to be refused, got: %v", input, err)
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	const input = "Warm-up ![](a.png),5000,,Sport,Easy.\n,Yes,1,,Right.\n,No ![](b.png),,,\n" +
+		"History,Dates and people,round\nThe WWII ended in:,,number~2\n,1945,\nSort,,order\n,1939,\n,1945,\n" +
+		"Music,,round\nWho wrote Carmen?,,text~1\n,Bizet,\nFavourite?,,poll~2\n,Bizet,\n,Verdi,\n,Dvořák,\n"
+	g, err := Parse(strings.NewReader(input), 10, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	var b strings.Builder
+	var w = csv.NewWriter(&b)
+	if err := w.WriteAll(Rows(g)); err != nil {
+		t.Fatalf("Writing rows failed: %v", err)
+	}
+	if parsed, err := Parse(strings.NewReader(b.String()), 10, 10); err != nil {
+		t.Fatalf("Parse refused the rows: %v\n%s", err, b.String())
+	} else if !reflect.DeepEqual(parsed, g) {
+		t.Fatalf("Expected the rows to describe the same game, got %#v instead of %#v", parsed, g)
+	}
+}
+
+func TestParse_rounds(t *testing.T) {
+	const input = "Warm-up,,\n,Yes,1\n,No,\nHistory,Dates and people,round\nThe WWII ended in:,,\n,1945,1\n,1939,\nWho was Caesar?,,\n,A Roman,1\n,A Greek,\nMusic,,round\nWho wrote Carmen?,,\n,Bizet,1\n,Verdi,\n"
+	g, err := Parse(strings.NewReader(input), 10, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	var expected = []Round{{Title: "History", Intro: "Dates and people"}, {Title: "Music"}}
+	if !reflect.DeepEqual(g.Rounds, expected) {
+		t.Fatalf("Parse returned rounds %#v while %#v were expected", g.Rounds, expected)
+	}
+	var rounds []int
+	for _, q := range g.Questions {
+		rounds = append(rounds, q.Round)
+	}
+	if !reflect.DeepEqual(rounds, []int{0, 1, 1, 2}) {
+		t.Fatalf("Expected the questions to belong to rounds 0, 1, 1 and 2, got %v", rounds)
+	}
+
+	var invalid = []string{
+		"History,,round\nMusic,,round\nWho wrote Carmen?,,\n,Bizet,1\n",
+		"History,,round\n,1945,1\n",
+		"Who wrote Carmen?,,\n,Bizet,1\nMusic,,round\n",
+		"History,,round,Chemistry\nWho wrote Carmen?,,\n,Bizet,1\n",
+	}
+	for _, input := range invalid {
+		if _, err := Parse(strings.NewReader(input), 10, 10); err != ErrInvalidSyntax {
+			t.Errorf("Expected %q to be refused, got: %v", input, err)
+		}
+	}
+}
+
+func TestParse_images(t *testing.T) {
+	const input = "Which flag is French? ![](flags.png),,\n,![](fr.png),1\n,![Italy](it.png) Italy,\n"
+	g, err := Parse(strings.NewReader(input), 10, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	var q = g.Questions[0]
+	if q.Title != "Which flag is French?" || q.Image != "flags.png" || q.Choices[0].Title != "" || q.Choices[0].Image != "fr.png" || q.Choices[1].Title != "Italy" || q.Choices[1].Image != "it.png" {
+		t.Fatalf("Expected the images to be split off the titles, got %#v", q)
+	}
+
+	var images = map[string]Image{"flags.png": {}, "fr.png": {}, "it.png": {}, "de.png": {}}
+	if err := g.AttachImages(images); err != nil {
+		t.Fatalf("Unexpected error from AttachImages: %v", err)
+	} else if len(g.Images) != 3 {
+		t.Fatalf("Expected only the referenced images to be attached, got %v", g.Images)
+	}
+	delete(images, "it.png")
+	if err := g.AttachImages(images); err != ErrMissingImage {
+		t.Fatalf("Expected a missing image to be reported, got: %v", err)
+	}
+
+	if _, err := Parse(strings.NewReader("![](flags.png),,\n,Yes,1\n"), 10, 10); err != ErrInvalidSyntax {
+		t.Fatalf("Expected a question with an image only to be refused, got: %v", err)
+	}
+}
+
+func TestReadImages(t *testing.T) {
+	var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	var bundle = func(files map[string][]byte) *bytes.Reader {
+		var b bytes.Buffer
+		zw := zip.NewWriter(&b)
+		for name, data := range files {
+			if w, err := zw.Create(name); err != nil {
+				t.Fatal(err)
+			} else if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return bytes.NewReader(b.Bytes())
+	}
+
+	var r = bundle(map[string][]byte{"flags/fr.png": png, "flags/.DS_Store": {0}})
+	if images, err := ReadImages(r, r.Size(), 10, 100, 1000); err != nil {
+		t.Fatalf("Unexpected error from ReadImages: %v", err)
+	} else if len(images) != 1 || images["fr.png"].Type != "image/png" || !bytes.Equal(images["fr.png"].Data, png) {
+		t.Fatalf("Expected a single PNG image named fr.png, got %v", images)
+	}
+
+	var invalid = map[error]map[string][]byte{
+		ErrInvalidImage:  {"fr.svg": []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")},
+		ErrTooManyImages: {"fr.png": png, "it.png": png},
+		ErrInvalidSyntax: {"a/fr.png": png, "b/fr.png": png},
+	}
+	for expected, files := range invalid {
+		var r = bundle(files)
+		if _, err := ReadImages(r, r.Size(), 1, 100, 1000); err != expected {
+			t.Errorf("Expected %v for %v, got: %v", expected, files, err)
+		}
+	}
+	r = bundle(map[string][]byte{"fr.png": png})
+	if _, err := ReadImages(r, r.Size(), 10, 8, 1000); err != ErrInvalidImage {
+		t.Errorf("Expected an image above the size limit to be refused, got: %v", err)
+	}
+	r = bundle(map[string][]byte{"fr.png": png, "it.png": png, "de.png": png})
+	if _, err := ReadImages(r, r.Size(), 10, 100, uint64(2*len(png))); err != ErrImagesTooLarge {
+		t.Errorf("Expected images above the total size limit to be refused, got: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package markdown
+
+import (
+	"html/template"
+	"strings"
+)
+
+type language struct {
+	lineComments  []string
+	blockComment  [2]string // empty if the language has no block comments
+	quotes        string
+	keywords      map[string]bool
+	caseSensitive bool
+}
+
+func words(s string) map[string]bool {
+	var m = make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var cLike = language{
+	lineComments:  []string{"//"},
+	blockComment:  [2]string{"/*", "*/"},
+	quotes:        `"'`,
+	caseSensitive: true,
+	keywords: words(`auto break case catch char class const continue default delete do double else enum extern false final finally
+		float for goto if int long namespace new nullptr private protected public return short signed sizeof static struct switch
+		template this throw true try typedef union unsigned using virtual void volatile while boolean byte extends implements
+		import instanceof interface null package super synchronized throws var string bool`),
+}
+
+// languages, which code blocks may be highlighted in, keyed by the name given after the opening fence
+var languages = map[string]language{
+	"go": {
+		lineComments:  []string{"//"},
+		blockComment:  [2]string{"/*", "*/"},
+		quotes:        "\"'`",
+		caseSensitive: true,
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto if import interface map
+			package range return select struct switch type var true false nil`),
+	},
+	"python": {
+		lineComments:  []string{"#"},
+		quotes:        `"'`,
+		caseSensitive: true,
+		keywords: words(`and as assert async await break class continue def del elif else except False finally for from global
+			if import in is lambda None nonlocal not or pass raise return True try while with yield`),
+	},
+	"javascript": {
+		lineComments:  []string{"//"},
+		blockComment:  [2]string{"/*", "*/"},
+		quotes:        "\"'`",
+		caseSensitive: true,
+		keywords: words(`async await break case catch class const continue debugger default delete do else export extends false
+			finally for function if import in instanceof let new null return super switch this throw true try typeof undefined
+			var void while with yield`),
+	},
+	"c":    cLike,
+	"cpp":  cLike,
+	"c++":  cLike,
+	"java": cLike,
+	"c#":   cLike,
+	"sql": {
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+		keywords: words(`add all alter and as asc between by case check column constraint create delete desc distinct drop else
+			end exists foreign from full group having in index inner insert into is join key left like limit not null on or order
+			outer primary references right select set table then union unique update values view when where`),
+	},
+	"bash": {
+		lineComments:  []string{"#"},
+		quotes:        `"'`,
+		caseSensitive: true,
+		keywords:      words(`case do done elif else esac export fi for function if in local return then until while`),
+	},
+}
+
+func init() {
+	languages["js"] = languages["javascript"]
+	languages["py"] = languages["python"]
+	languages["sh"] = languages["bash"]
+}
+
+// Writes the code escaped, with keywords, strings, comments and numbers wrapped in spans of classes kw, str, com and num.
+// Code in languages missing from languages is only escaped.
+func highlight(b *strings.Builder, code string, lang string) {
+	l, ok := languages[lang]
+	if !ok {
+		b.WriteString(template.HTMLEscapeString(code))
+		return
+	}
+	var span = func(class string, text string) {
+		b.WriteString(`<span class="` + class + `">` + template.HTMLEscapeString(text) + "</span>")
+	}
+	for i := 0; i < len(code); {
+		var rest = code[i:]
+		if end := commentEnd(rest, l); end > 0 {
+			span("com", rest[:end])
+			i += end
+		} else if strings.IndexByte(l.quotes, rest[0]) >= 0 {
+			var end = stringEnd(rest)
+			span("str", rest[:end])
+			i += end
+		} else if isDigit(rest[0]) {
+			var end = 1
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			span("num", rest[:end])
+			i += end
+		} else if isWordByte(rest[0]) {
+			var end = 1
+			for end < len(rest) && isWordByte(rest[end]) {
+				end++
+			}
+			var word = rest[:end]
+			if l.keywords[word] || !l.caseSensitive && l.keywords[strings.ToLower(word)] {
+				span("kw", word)
+			} else {
+				b.WriteString(template.HTMLEscapeString(word))
+			}
+			i += end
+		} else {
+			var end = 1
+			for end < len(rest) && !isWordByte(rest[end]) && strings.IndexByte(l.quotes, rest[end]) < 0 && commentEnd(rest[end:], l) == 0 {
+				end++
+			}
+			b.WriteString(template.HTMLEscapeString(rest[:end]))
+			i += end
+		}
+	}
+}
+
+// Returns the length of the comment s starts with, zero if it does not start with one
+func commentEnd(s string, l language) int {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	if l.blockComment[0] != "" && strings.HasPrefix(s, l.blockComment[0]) {
+		if end := strings.Index(s[len(l.blockComment[0]):], l.blockComment[1]); end >= 0 {
+			return len(l.blockComment[0]) + end + len(l.blockComment[1])
+		}
+		return len(s)
+	}
+	return 0
+}
+
+// Returns the length of the string literal s starts with, which ends with the same quote or a line end
+func stringEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case s[0]:
+			return i + 1
+		case '\n':
+			if s[0] != '`' {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Reports whether c may be part of an identifier, bytes of multi-byte characters included
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c >= 0x80
+}
@@ -0,0 +1,201 @@
+package markdown
+
+import (
+	"html/template"
+	"strings"
+	"vkane.cz/tinyquiz/pkg/mathml"
+)
+
+// Renders a safe subset of Markdown used in titles of questions and choices.
+// Supported are `inline code`, fenced code blocks (optionally highlighted, see highlight), **bold**, *italic*,
+// $inline$ and $$displayed$$ LaTeX formulas (see mathml.Convert), line breaks and backslash escapes.
+// Everything else, raw HTML included, is escaped and shown as written.
+// The result consists of phrasing content only, so that it may be put into headings and buttons.
+func Render(s string) template.HTML {
+	var b strings.Builder
+	var lines = strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	var text []string // lines of text waiting to be rendered
+	var flush = func() {
+		if t := strings.Trim(strings.Join(text, "\n"), "\n"); t != "" {
+			renderInline(&b, t)
+		}
+		text = nil
+	}
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+			text = append(text, lines[i])
+			continue
+		}
+		flush()
+		var lang = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "```")))
+		var code []string
+		for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "```"; i++ {
+			code = append(code, lines[i])
+		}
+		b.WriteString(`<code class="block`)
+		if validLanguage(lang) {
+			b.WriteString(" language-" + lang)
+		}
+		b.WriteString(`">`)
+		highlight(&b, strings.Join(code, "\n"), lang)
+		b.WriteString("</code>")
+	}
+	flush()
+	return template.HTML(b.String())
+}
+
+// characters, which may be escaped by a backslash
+const escapable = "\\`*_{}[]()#+-.!<>$"
+
+func renderInline(b *strings.Builder, s string) {
+	var plain int // start of the text not written yet
+	var writePlain = func(end int) {
+		b.WriteString(template.HTMLEscapeString(s[plain:end]))
+	}
+	for i := 0; i < len(s); {
+		var next, html = i, ""
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0:
+			next, html = i+2, template.HTMLEscapeString(s[i+1:i+2])
+		case s[i] == '`':
+			var n = len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			if j := findBackticks(s[i+n:], n); j >= 0 {
+				var code = s[i+n : i+n+j]
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				next, html = i+n+j+n, "<code>"+template.HTMLEscapeString(code)+"</code>"
+			} else {
+				next, html = i+n, strings.Repeat("`", n)
+			}
+		case s[i] == '$':
+			if tex, end, display := findMath(s, i); end < 0 {
+				next, html = i+1, "$"
+			} else if m, err := mathml.Convert(tex, display); err == nil {
+				next, html = end, m
+			} else {
+				// formulas, which cannot be converted, are shown as written
+				next, html = end, template.HTMLEscapeString(s[i:end])
+			}
+		case strings.HasPrefix(s[i:], "**"):
+			next, html = emphasis(s, i, "**", "strong")
+		case s[i] == '*':
+			next, html = emphasis(s, i, "*", "em")
+		case s[i] == '\n':
+			next, html = i+1, "<br>"
+		default:
+			i++
+			continue
+		}
+		writePlain(i)
+		b.WriteString(html)
+		i, plain = next, next
+	}
+	writePlain(len(s))
+}
+
+// Renders the text enclosed in delim starting at s[i] as the given element.
+// If there is no suitable closing delimiter, the opening one is kept as written.
+// Returns the index just behind the rendered part of s and the HTML.
+func emphasis(s string, i int, delim string, element string) (int, string) {
+	var start = i + len(delim)
+	if j := findClosing(s[start:], delim); j > 0 {
+		var inner strings.Builder
+		renderInline(&inner, s[start:start+j])
+		return start + j + len(delim), "<" + element + ">" + inner.String() + "</" + element + ">"
+	}
+	return start, delim
+}
+
+// Returns the index of delim closing emphasis, which starts at the beginning of s, or -1 if there is none.
+// The emphasised text may neither start nor end with a space, code spans and escaped characters are skipped.
+func findClosing(s string, delim string) int {
+	if s == "" || s[0] == ' ' || s[0] == '\n' {
+		return -1
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '`':
+			var n = len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			if j := findBackticks(s[i+n:], n); j >= 0 {
+				i += n + j + n - 1
+			} else {
+				i += n - 1
+			}
+		case s[i] == '$':
+			if _, end, _ := findMath(s, i); end >= 0 {
+				i = end - 1
+			}
+		case strings.HasPrefix(s[i:], "**") && delim == "*":
+			// nested strong emphasis
+			if j := findClosing(s[i+2:], "**"); j > 0 {
+				i += 2 + j + 1
+			} else {
+				i++
+			}
+		case strings.HasPrefix(s[i:], delim):
+			if i > 0 && s[i-1] != ' ' && s[i-1] != '\n' {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Finds the formula starting at s[i], which is either displayed and enclosed in $$ or inline and enclosed in $.
+// To leave amounts like $5 alone, an inline formula may neither start nor end with a space and may not be followed by a digit.
+// Returns the formula and the index just behind it or -1 if there is no formula.
+func findMath(s string, i int) (string, int, bool) {
+	if strings.HasPrefix(s[i:], "$$") {
+		if j := strings.Index(s[i+2:], "$$"); j >= 0 {
+			return s[i+2 : i+2+j], i + 2 + j + 2, true
+		}
+		return "", -1, false
+	}
+	var rest = s[i+1:]
+	if rest == "" || rest[0] == ' ' || rest[0] == '\n' {
+		return "", -1, false
+	}
+	for j := 1; j < len(rest); j++ {
+		if rest[j] == '\\' {
+			j++
+		} else if rest[j] == '$' {
+			if rest[j-1] != ' ' && rest[j-1] != '\n' && (j+1 == len(rest) || rest[j+1] < '0' || rest[j+1] > '9') {
+				return rest[:j], i + 1 + j + 1, false
+			}
+			return "", -1, false
+		}
+	}
+	return "", -1, false
+}
+
+// Returns the index of the first run of exactly n backticks in s or -1 if there is none
+func findBackticks(s string, n int) int {
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		var run = len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// Reports whether lang may be used in a class name
+func validLanguage(lang string) bool {
+	if lang == "" || len(lang) > 16 {
+		return false
+	}
+	for _, r := range lang {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '+' || r == '#' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
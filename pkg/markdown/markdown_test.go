@@ -0,0 +1,59 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	var cases = map[string]string{
+		"What is 2 * 3 * 4?":                      "What is 2 * 3 * 4?",
+		"Which is **not** a *keyword*?":           "Which is <strong>not</strong> a <em>keyword</em>?",
+		"*very **important** thing*":              "<em>very <strong>important</strong> thing</em>",
+		"What does `a < b && c` mean?":            "What does <code>a &lt; b &amp;&amp; c</code> mean?",
+		"Use `` `backticks` `` in Markdown":       "Use <code>`backticks`</code> in Markdown",
+		"`**not bold**`":                          "<code>**not bold**</code>",
+		"snake_case_name and \\*literal\\*":       "snake_case_name and *literal*",
+		"first line\nsecond line":                 "first line<br>second line",
+		"<script>alert(1)</script>":               "&lt;script&gt;alert(1)&lt;/script&gt;",
+		"<b onclick=\"x()\">**bold**</b>":         "&lt;b onclick=&#34;x()&#34;&gt;<strong>bold</strong>&lt;/b&gt;",
+		"[link](javascript:alert(1))":             "[link](javascript:alert(1))",
+		"unclosed **bold and `code":               "unclosed **bold and `code",
+		"What prints?\n```\n  if x:\n\tpass\n```": "What prints?<code class=\"block\">  if x:\n\tpass</code>",
+		"```unclosed <\nfence":                    "<code class=\"block\">fence</code>",
+		"```\"><script>\nx\n```":                  "<code class=\"block\">x</code>",
+	}
+	for s, expected := range cases {
+		if actual := string(Render(s)); actual != expected {
+			t.Errorf("Render(%q) returned %q while %q was expected", s, actual, expected)
+		}
+	}
+}
+
+func TestRender_highlight(t *testing.T) {
+	var cases = map[string]string{
+		"```go\nfunc f() string { return \"<\" + 42 } // done\n```": `<code class="block language-go"><span class="kw">func</span> f() string { <span class="kw">return</span> <span class="str">&#34;&lt;&#34;</span> + <span class="num">42</span> } <span class="com">// done</span></code>`,
+		"```SQL\nselect * FROM t -- all\n```":                       `<code class="block language-sql"><span class="kw">select</span> * <span class="kw">FROM</span> t <span class="com">-- all</span></code>`,
+		"```python\nprint('a # b')  # c\n```":                       `<code class="block language-python">print(<span class="str">&#39;a # b&#39;</span>)  <span class="com"># c</span></code>`,
+		"```brainfuck\n+[<]\n```":                                   `<code class="block language-brainfuck">+[&lt;]</code>`,
+	}
+	for s, expected := range cases {
+		if actual := string(Render(s)); actual != expected {
+			t.Errorf("Render(%q) returned\n%s\nwhile expected was\n%s", s, actual, expected)
+		}
+	}
+}
+
+func TestRender_math(t *testing.T) {
+	var cases = map[string]string{
+		"Is $\\frac{a}{b}$ a fraction?": "Is <math><mfrac><mi>a</mi><mi>b</mi></mfrac></math> a fraction?",
+		"Solve $$x^2 = 4$$":             `Solve <math display="block"><mrow><msup><mi>x</mi><mn>2</mn></msup><mo>=</mo><mn>4</mn></mrow></math>`,
+		"Costs $5 and $10":              "Costs $5 and $10",
+		"Costs \\$x$":                   "Costs $x$",
+		"$\\frac{a}$ stays":             "$\\frac{a}$ stays",
+		"$<script>$":                    "<math><mrow><mo>&lt;</mo><mi>s</mi><mi>c</mi><mi>r</mi><mi>i</mi><mi>p</mi><mi>t</mi><mo>&gt;</mo></mrow></math>",
+		"*$a*b$*":                       "<em><math><mrow><mi>a</mi><mo>∗</mo><mi>b</mi></mrow></math></em>",
+	}
+	for s, expected := range cases {
+		if actual := string(Render(s)); actual != expected {
+			t.Errorf("Render(%q) returned %q while %q was expected", s, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package mathml
+
+import (
+	"errors"
+	"html/template"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrSyntax = errors.New("malformed formula")
+var ErrUnsupported = errors.New("unsupported command in formula")
+
+// Converts a LaTeX formula to MathML, either inline or displayed as a block.
+// The common subset is supported: fractions, powers and indices, roots, Greek letters, operators and relations,
+// functions like \sin, big operators like \sum, \left and \right delimiters, accents, \text and spacing.
+// Any text of the formula ends up escaped in the result.
+func Convert(tex string, display bool) (string, error) {
+	var p = parser{s: tex, display: display}
+	nodes, err := p.parseRow()
+	if err != nil {
+		return "", err
+	} else if p.i < len(p.s) {
+		// an unmatched closing brace
+		return "", ErrSyntax
+	}
+	var b strings.Builder
+	if display {
+		b.WriteString(`<math display="block">`)
+	} else {
+		b.WriteString("<math>")
+	}
+	b.WriteString(row(nodes))
+	b.WriteString("</math>")
+	return b.String(), nil
+}
+
+type parser struct {
+	s       string
+	i       int
+	display bool
+}
+
+// Wraps nodes into a single one
+func row(nodes []string) string {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return "<mrow>" + strings.Join(nodes, "") + "</mrow>"
+}
+
+func element(name string, text string) string {
+	return "<" + name + ">" + template.HTMLEscapeString(text) + "</" + name + ">"
+}
+
+func (p *parser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t' || p.s[p.i] == '\n' || p.s[p.i] == '\r') {
+		p.i++
+	}
+}
+
+// Parses terms up to the end of the formula, a closing brace or \right, which are left unconsumed
+func (p *parser) parseRow() ([]string, error) {
+	var nodes []string
+	for {
+		p.skipSpace()
+		if p.i >= len(p.s) || p.s[p.i] == '}' || strings.HasPrefix(p.s[p.i:], `\right`) {
+			return nodes, nil
+		}
+		if node, err := p.parseTerm(); err == nil {
+			nodes = append(nodes, node)
+		} else {
+			return nil, err
+		}
+	}
+}
+
+// Parses an atom with its optional superscript and subscript
+func (p *parser) parseTerm() (string, error) {
+	var base string
+	var bigOperator bool
+	if p.s[p.i] == '^' || p.s[p.i] == '_' {
+		base = "<mrow></mrow>"
+	} else if atom, big, err := p.parseAtom(false); err == nil {
+		base, bigOperator = atom, big
+	} else {
+		return "", err
+	}
+
+	var sub, sup string
+	for {
+		p.skipSpace()
+		if p.i >= len(p.s) || (p.s[p.i] != '^' && p.s[p.i] != '_') {
+			break
+		}
+		var target = &sup
+		if p.s[p.i] == '_' {
+			target = &sub
+		}
+		if *target != "" {
+			// double superscript or subscript
+			return "", ErrSyntax
+		}
+		p.i++
+		if arg, err := p.parseArgument(); err == nil {
+			*target = arg
+		} else {
+			return "", err
+		}
+	}
+
+	// limits of big operators go under and over them in display mode
+	var under, over, both = "msub", "msup", "msubsup"
+	if bigOperator && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != "" && sup != "":
+		return "<" + both + ">" + base + sub + sup + "</" + both + ">", nil
+	case sub != "":
+		return "<" + under + ">" + base + sub + "</" + under + ">", nil
+	case sup != "":
+		return "<" + over + ">" + base + sup + "</" + over + ">", nil
+	default:
+		return base, nil
+	}
+}
+
+// Parses an argument of a command or a script, which is either a group in braces or a single atom
+func (p *parser) parseArgument() (string, error) {
+	p.skipSpace()
+	if p.i >= len(p.s) {
+		return "", ErrSyntax
+	}
+	atom, _, err := p.parseAtom(true)
+	return atom, err
+}
+
+// Parses a group in braces, the opening one included
+func (p *parser) parseGroup() (string, error) {
+	p.skipSpace()
+	if p.i >= len(p.s) || p.s[p.i] != '{' {
+		return "", ErrSyntax
+	}
+	p.i++
+	nodes, err := p.parseRow()
+	if err != nil {
+		return "", err
+	} else if p.i >= len(p.s) || p.s[p.i] != '}' {
+		return "", ErrSyntax
+	}
+	p.i++
+	if len(nodes) == 0 {
+		return "<mrow></mrow>", nil
+	}
+	return row(nodes), nil
+}
+
+// Parses raw text in braces, the opening one included, as used by \text
+func (p *parser) parseText() (string, error) {
+	p.skipSpace()
+	if p.i >= len(p.s) || p.s[p.i] != '{' {
+		return "", ErrSyntax
+	}
+	var depth int
+	for j := p.i; j < len(p.s); j++ {
+		switch p.s[j] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				var text = p.s[p.i+1 : j]
+				p.i = j + 1
+				return text, nil
+			}
+		}
+	}
+	return "", ErrSyntax
+}
+
+// Parses a single atom. Numbers of more than one digit are only allowed outside arguments, as x^23 means x², followed by 3.
+// Reports whether the atom is a big operator taking limits.
+func (p *parser) parseAtom(argument bool) (string, bool, error) {
+	var c = p.s[p.i]
+	switch {
+	case c == '{':
+		g, err := p.parseGroup()
+		return g, false, err
+	case c == '}' || c == '^' || c == '_' || c == '&' || c == '#' || c == '%':
+		return "", false, ErrSyntax
+	case c == '\\':
+		return p.parseCommand()
+	case c >= '0' && c <= '9' || c == '.' && p.i+1 < len(p.s) && p.s[p.i+1] >= '0' && p.s[p.i+1] <= '9':
+		var end = p.i + 1
+		for !argument && end < len(p.s) && (p.s[end] >= '0' && p.s[end] <= '9' || p.s[end] == '.' && end+1 < len(p.s) && p.s[end+1] >= '0' && p.s[end+1] <= '9') {
+			end++
+		}
+		var number = p.s[p.i:end]
+		p.i = end
+		return element("mn", number), false, nil
+	case c == '~':
+		p.i++
+		return `<mspace width="0.33em"></mspace>`, false, nil
+	}
+
+	r, size := utf8.DecodeRuneInString(p.s[p.i:])
+	p.i += size
+	if unicode.IsLetter(r) {
+		return element("mi", string(r)), false, nil
+	} else if o, ok := operatorCharacters[r]; ok {
+		return element("mo", o), false, nil
+	} else {
+		return element("mo", string(r)), false, nil
+	}
+}
+
+// operators written as characters, which are shown differently
+var operatorCharacters = map[rune]string{
+	'-':  "−",
+	'*':  "∗",
+	'\'': "′",
+}
+
+// Parses a command including its arguments
+func (p *parser) parseCommand() (string, bool, error) {
+	var start = p.i + 1
+	var end = start
+	for end < len(p.s) && (p.s[end] >= 'a' && p.s[end] <= 'z' || p.s[end] >= 'A' && p.s[end] <= 'Z') {
+		end++
+	}
+	if end == start {
+		// a command made of a single non-letter, e.g. \{ or \,
+		if end >= len(p.s) {
+			return "", false, ErrSyntax
+		}
+		_, size := utf8.DecodeRuneInString(p.s[end:])
+		end += size
+	}
+	var name = p.s[start:end]
+	p.i = end
+
+	if g, ok := greek[name]; ok {
+		if unicode.IsUpper([]rune(g)[0]) {
+			return `<mi mathvariant="normal">` + g + "</mi>", false, nil
+		}
+		return element("mi", g), false, nil
+	} else if s, ok := symbols[name]; ok {
+		return element("mo", s), false, nil
+	} else if s, ok := identifiers[name]; ok {
+		return element("mi", s), false, nil
+	} else if s, ok := bigOperators[name]; ok {
+		return element("mo", s), true, nil
+	} else if functions[name] {
+		return element("mi", name), name == "lim" || name == "max" || name == "min", nil
+	} else if w, ok := spaces[name]; ok {
+		return `<mspace width="` + w + `"></mspace>`, false, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		var numerator, denominator string
+		var err error
+		if numerator, err = p.parseArgument(); err != nil {
+			return "", false, err
+		}
+		if denominator, err = p.parseArgument(); err != nil {
+			return "", false, err
+		}
+		return "<mfrac>" + numerator + denominator + "</mfrac>", false, nil
+	case "sqrt":
+		var index string
+		p.skipSpace()
+		if p.i < len(p.s) && p.s[p.i] == '[' {
+			if end := strings.IndexByte(p.s[p.i:], ']'); end >= 0 {
+				if i, err := Convert(p.s[p.i+1:p.i+end], false); err == nil {
+					index = strings.TrimSuffix(strings.TrimPrefix(i, "<math>"), "</math>")
+					if index == "" {
+						index = "<mrow></mrow>"
+					}
+				} else {
+					return "", false, err
+				}
+				p.i += end + 1
+			} else {
+				return "", false, ErrSyntax
+			}
+		}
+		radicand, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		if index != "" {
+			return "<mroot>" + radicand + index + "</mroot>", false, nil
+		}
+		return "<msqrt>" + radicand + "</msqrt>", false, nil
+	case "text", "mathrm":
+		text, err := p.parseText()
+		if err != nil {
+			return "", false, err
+		}
+		if name == "text" {
+			return element("mtext", text), false, nil
+		}
+		return `<mi mathvariant="normal">` + template.HTMLEscapeString(text) + "</mi>", false, nil
+	case "vec", "bar", "overline", "hat", "dot", "tilde":
+		arg, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		return `<mover accent="true">` + arg + element("mo", accents[name]) + "</mover>", false, nil
+	case "left":
+		return p.parseDelimited()
+	}
+	return "", false, ErrUnsupported
+}
+
+// Parses the contents of \left and \right delimiters, \left itself already consumed
+func (p *parser) parseDelimited() (string, bool, error) {
+	open, err := p.parseDelimiter()
+	if err != nil {
+		return "", false, err
+	}
+	nodes, err := p.parseRow()
+	if err != nil {
+		return "", false, err
+	} else if !strings.HasPrefix(p.s[p.i:], `\right`) {
+		return "", false, ErrSyntax
+	}
+	p.i += len(`\right`)
+	close, err := p.parseDelimiter()
+	if err != nil {
+		return "", false, err
+	}
+	return "<mrow>" + open + strings.Join(nodes, "") + close + "</mrow>", false, nil
+}
+
+// Parses a delimiter following \left or \right, a dot stands for none
+func (p *parser) parseDelimiter() (string, error) {
+	p.skipSpace()
+	if p.i >= len(p.s) {
+		return "", ErrSyntax
+	}
+	var d string
+	if p.s[p.i] == '\\' {
+		var end = p.i + 1
+		for end < len(p.s) && (p.s[end] >= 'a' && p.s[end] <= 'z') {
+			end++
+		}
+		if end == p.i+1 && end < len(p.s) {
+			end++
+		}
+		var ok bool
+		if d, ok = delimiters[p.s[p.i+1:end]]; !ok {
+			return "", ErrUnsupported
+		}
+		p.i = end
+	} else if strings.IndexByte("()[]|./", p.s[p.i]) >= 0 {
+		d = p.s[p.i : p.i+1]
+		p.i++
+	} else {
+		return "", ErrSyntax
+	}
+	if d == "." {
+		return "", nil
+	}
+	return `<mo stretchy="true">` + template.HTMLEscapeString(d) + "</mo>", nil
+}
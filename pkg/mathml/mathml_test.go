@@ -0,0 +1,64 @@
+package mathml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	var cases = map[string]string{
+		`\frac{a}{b}`:                `<math><mfrac><mi>a</mi><mi>b</mi></mfrac></math>`,
+		`x^2 + y_1`:                  `<math><mrow><msup><mi>x</mi><mn>2</mn></msup><mo>+</mo><msub><mi>y</mi><mn>1</mn></msub></mrow></math>`,
+		`x^23`:                       `<math><mrow><msup><mi>x</mi><mn>2</mn></msup><mn>3</mn></mrow></math>`,
+		`a_i^{n-1}`:                  `<math><msubsup><mi>a</mi><mi>i</mi><mrow><mi>n</mi><mo>−</mo><mn>1</mn></mrow></msubsup></math>`,
+		`\sqrt{2} \approx 1.41`:      `<math><mrow><msqrt><mn>2</mn></msqrt><mo>≈</mo><mn>1.41</mn></mrow></math>`,
+		`\sqrt[3]{x}`:                `<math><mroot><mi>x</mi><mn>3</mn></mroot></math>`,
+		`\alpha \cdot \Omega`:        `<math><mrow><mi>α</mi><mo>⋅</mo><mi mathvariant="normal">Ω</mi></mrow></math>`,
+		`\sin x \le 1`:               `<math><mrow><mi>sin</mi><mi>x</mi><mo>≤</mo><mn>1</mn></mrow></math>`,
+		`\left( \frac{1}{2} \right)`: `<math><mrow><mo stretchy="true">(</mo><mfrac><mn>1</mn><mn>2</mn></mfrac><mo stretchy="true">)</mo></mrow></math>`,
+		`\text{if } x < 0`:           `<math><mrow><mtext>if </mtext><mi>x</mi><mo>&lt;</mo><mn>0</mn></mrow></math>`,
+		`\vec{F}`:                    `<math><mover accent="true"><mi>F</mi><mo>→</mo></mover></math>`,
+		`\{x\}`:                      `<math><mrow><mo>{</mo><mi>x</mi><mo>}</mo></mrow></math>`,
+		``:                           `<math><mrow></mrow></math>`,
+	}
+	for tex, expected := range cases {
+		if actual, err := Convert(tex, false); err != nil {
+			t.Errorf("Convert(%q) failed: %v", tex, err)
+		} else if actual != expected {
+			t.Errorf("Convert(%q) returned\n%s\nwhile expected was\n%s", tex, actual, expected)
+		}
+	}
+}
+
+func TestConvert_display(t *testing.T) {
+	const expected = `<math display="block"><mrow><munderover><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover><mi>i</mi></mrow></math>`
+	if actual, err := Convert(`\sum_{i=1}^n i`, true); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	} else if actual != expected {
+		t.Fatalf("Convert returned\n%s\nwhile expected was\n%s", actual, expected)
+	}
+	if actual, err := Convert(`\sum_{i=1}^n i`, false); err != nil || !strings.HasPrefix(actual, `<math><mrow><msubsup><mo>∑</mo>`) {
+		t.Fatalf("Expected limits of inline sums to be scripts, got %s, %v", actual, err)
+	}
+}
+
+func TestConvert_invalid(t *testing.T) {
+	var cases = map[string]error{
+		`\frac{a}`:       ErrSyntax,
+		`x^`:             ErrSyntax,
+		`x^2^3`:          ErrSyntax,
+		`{a`:             ErrSyntax,
+		`a}`:             ErrSyntax,
+		`\left( x`:       ErrSyntax,
+		`x \right)`:      ErrSyntax,
+		`a & b`:          ErrSyntax,
+		`\begin{matrix}`: ErrUnsupported,
+		`\href{x}{y}`:    ErrUnsupported,
+		`\`:              ErrSyntax,
+	}
+	for tex, expected := range cases {
+		if _, err := Convert(tex, false); err != expected {
+			t.Errorf("Convert(%q) returned error %v while %v was expected", tex, err, expected)
+		}
+	}
+}
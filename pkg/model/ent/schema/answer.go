@@ -15,6 +15,10 @@ func (Answer) Fields() []ent.Field {
 	return []ent.Field{
 		field.UUID("id", uuid.Nil).Immutable().Unique(),
 		field.Time("answered").Immutable(),
+		field.Text("text").MaxLen(256).Optional(),   // typed answer to a text question, which has no choice
+		field.Bool("accepted").Default(false),       // accepted by the organiser despite not matching
+		field.Float("number").Optional().Nillable(), // guess to a number question, which has no choice
+		field.Int("position").Optional().Nillable(), // where the player has put the choice in an ordering question
 	}
 }
 
@@ -25,10 +29,13 @@ func (Answer) Indexes() []ent.Index {
 func (Answer) Edges() []ent.Edge {
 	return []ent.Edge{
 		edge.From("choice", Choice.Type).
+			Ref("answers").
+			Unique(),
+		edge.From("answerer", Player.Type).
 			Ref("answers").
 			Unique().
 			Required(),
-		edge.From("answerer", Player.Type).
+		edge.From("askedQuestion", AskedQuestion.Type).
 			Ref("answers").
 			Unique().
 			Required(),
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
 	"github.com/google/uuid"
@@ -16,6 +17,9 @@ func (AskedQuestion) Fields() []ent.Field {
 		field.UUID("id", uuid.Nil).Immutable(),
 		field.Time("asked").Immutable(),
 		field.Time("ended").Optional().Nillable(),
+		field.Time("paused").Optional().Nillable(),
+		field.Uint64("extension").Default(0), // in milliseconds, the time added to the question's defaultLength including pauses
+		field.Bool("skipped").Default(false), // skipped questions do not count towards the results
 	}
 }
 
@@ -33,5 +37,13 @@ func (AskedQuestion) Edges() []ent.Edge {
 			Ref("asked").
 			Unique().
 			Required(),
+		// the only player the question has been asked to in a self-paced session
+		edge.From("player", Player.Type).
+			Ref("askedQuestions").
+			Unique(),
+		edge.To("answers", Answer.Type).
+			Annotations(entsql.Annotation{
+				OnDelete: entsql.Cascade,
+			}),
 	}
 }
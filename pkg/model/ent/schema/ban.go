@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"github.com/google/uuid"
+)
+
+// A player name, which may not join the session again
+type Ban struct {
+	ent.Schema
+}
+
+func (Ban) Fields() []ent.Field {
+	return []ent.Field{
+		field.UUID("id", uuid.Nil).Immutable(),
+		field.Text("name").MaxLen(64).MinLen(1).Immutable(),
+		field.Time("created").Immutable(),
+	}
+}
+
+func (Ban) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("name").Edges("session").Unique(),
+	}
+}
+
+func (Ban) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("session", Session.Type).
+			Ref("bans").
+			Unique().
+			Required(),
+	}
+}
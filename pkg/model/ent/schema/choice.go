@@ -15,8 +15,11 @@ type Choice struct {
 func (Choice) Fields() []ent.Field {
 	return []ent.Field{
 		field.UUID("id", uuid.Nil).Immutable(),
-		field.Text("title").MinLen(1).MaxLen(256),
+		field.Text("title").MaxLen(256), // may be empty if there is an image
 		field.Bool("correct"),
+		field.Int("order").Default(0),                  // the position in the question, which is the correct one in ordering questions
+		field.UUID("image", uuid.Nil).Optional(),       // ID of an image of the game shown with the choice, uuid.Nil if none
+		field.Text("feedback").MaxLen(512).Default(""), // shown to players who picked the choice once the question closes, empty if none
 	}
 }
 
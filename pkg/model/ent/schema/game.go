@@ -19,6 +19,8 @@ func (Game) Fields() []ent.Field {
 		field.Time("created").Immutable(),
 		field.Text("author").MaxLen(64),
 		field.Text("code").MinLen(1).Unique(),
+		field.Int("version").Positive().Default(1),    // editing a game that has been played creates its next version
+		field.UUID("edit_token", uuid.Nil).Optional(), // secret part of the editor's URL shared by all versions, uuid.Nil if the game cannot be edited
 	}
 }
 
@@ -32,5 +34,18 @@ func (Game) Edges() []ent.Edge {
 			Annotations(entsql.Annotation{
 				OnDelete: entsql.Cascade,
 			}),
+		edge.To("images", Image.Type).
+			Annotations(entsql.Annotation{
+				OnDelete: entsql.Cascade,
+			}),
+		edge.To("rounds", Round.Type).
+			Annotations(entsql.Annotation{
+				OnDelete: entsql.Cascade,
+			}),
+		// nil unless the game has been edited after being played
+		edge.To("next", Game.Type).
+			Unique().
+			From("previous").
+			Unique(),
 	}
 }
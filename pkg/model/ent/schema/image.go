@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"github.com/google/uuid"
+)
+
+// An image uploaded with a game, which its questions and choices refer to
+type Image struct {
+	ent.Schema
+}
+
+func (Image) Fields() []ent.Field {
+	return []ent.Field{
+		field.UUID("id", uuid.Nil).Immutable(),
+		field.String("name").MaxLen(255).MinLen(1).Immutable(), // the file name in the bundle
+		field.String("type").Immutable(),                       // MIME type
+		field.Bytes("data").Immutable(),
+	}
+}
+
+func (Image) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("name").Edges("game").Unique(),
+	}
+}
+
+func (Image) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("game", Game.Type).
+			Ref("images").
+			Unique().
+			Required(),
+	}
+}
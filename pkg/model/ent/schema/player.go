@@ -20,6 +20,7 @@ func (Player) Fields() []ent.Field {
 		field.Text("name").MaxLen(64).MinLen(1).Match(regexp.MustCompile("(?:[a-z]|[A-Z]|_|-|.|,|[0-9])+")),
 		field.Time("joined").Immutable(),
 		field.Bool("organiser").Default(false),
+		field.Time("kicked").Optional().Nillable(), // the player has been removed from the session, but their answers are kept
 	}
 }
 
@@ -35,9 +36,16 @@ func (Player) Edges() []ent.Edge {
 			Ref("players").
 			Unique().
 			Required(),
+		edge.From("team", Team.Type).
+			Ref("members").
+			Unique(),
 		edge.To("answers", Answer.Type).
 			Annotations(entsql.Annotation{
 				OnDelete: entsql.Cascade,
 			}),
+		edge.To("askedQuestions", AskedQuestion.Type).
+			Annotations(entsql.Annotation{
+				OnDelete: entsql.Cascade,
+			}),
 	}
 }
@@ -19,6 +19,14 @@ func (Question) Fields() []ent.Field {
 		field.Text("title").MaxLen(256).MinLen(1),
 		field.Int("order"),
 		field.Uint64("defaultLength"), // in milliseconds
+		field.Enum("type").Values("single", "multiple", "text", "number", "ordering", "poll").Default("single"),
+		field.Uint8("tolerance").Default(0),        // typos forgiven in answers to text questions
+		field.Float("value").Optional().Nillable(), // the correct answer to number questions
+		field.Float("margin").Default(0),           // the distance from value at which guesses to number questions stop scoring, zero if the closest guesses win
+		field.Uint8("votes").Default(1),            // the number of choices a player may vote for in a poll
+		field.UUID("image", uuid.Nil).Optional(),   // ID of an image of the game shown with the question, uuid.Nil if none
+		field.String("category").MaxLen(255).Default(""),
+		field.Text("explanation").MaxLen(1024).Default(""), // shown once the question closes, empty if none
 	}
 }
 
@@ -42,5 +50,11 @@ func (Question) Edges() []ent.Edge {
 			Annotations(entsql.Annotation{
 				OnDelete: entsql.Restrict,
 			}),
+		edge.From("pools", Session.Type).
+			Ref("pool"),
+		// nil if the question belongs to no round
+		edge.From("round", Round.Type).
+			Ref("questions").
+			Unique(),
 	}
 }
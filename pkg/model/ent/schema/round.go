@@ -0,0 +1,39 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"github.com/google/uuid"
+)
+
+// A named group of consecutive questions of a game, which is introduced by a title slide and scored on its own as well
+type Round struct {
+	ent.Schema
+}
+
+func (Round) Fields() []ent.Field {
+	return []ent.Field{
+		field.UUID("id", uuid.Nil).Immutable(),
+		field.Text("title").MaxLen(256).MinLen(1),
+		field.Text("intro").MaxLen(1024).Default(""), // shown on the title slide
+		field.Int("order"),
+	}
+}
+
+func (Round) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("order").Edges("game").Unique(),
+	}
+}
+
+func (Round) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("game", Game.Type).
+			Ref("rounds").
+			Unique().
+			Required(),
+		edge.To("questions", Question.Type),
+	}
+}
@@ -17,7 +17,28 @@ func (Session) Fields() []ent.Field {
 		field.UUID("id", uuid.Nil).Immutable(),
 		field.Time("created").Immutable(),
 		field.Time("started").Nillable().Optional(),
+		field.Time("finished").Nillable().Optional(),
 		field.String("code").MinLen(1).Immutable().Unique(),
+		field.Enum("scoring").Values("simple", "timeWeighted").Default("simple"),
+		field.Bool("autoAdvance").Default(false), // close questions automatically when time runs out or everyone has answered
+		field.Bool("autopilot").Default(false),   // run the whole session without the organiser
+		field.Uint64("breakLength").Default(0),   // in milliseconds, how long autopilot waits between questions
+		field.Uint64("minPlayers").Default(1),    // how many players autopilot waits for before the first question
+		// how member scores combine in team mode, i.e. when the session has teams
+		field.Enum("teamScoring").Values("sum", "average", "best").Default("sum"),
+		// sets of choices picked in multiple-choice questions score partially instead of all-or-nothing
+		field.Bool("partialCredit").Default(false),
+		// every player goes through the questions on their own until the deadline, if any
+		field.Bool("selfPaced").Default(false),
+		field.Time("deadline").Optional().Nillable(),
+		// questions are asked in a pseudo-random order derived from the session's ID instead of the game's one
+		field.Bool("shuffleQuestions").Default(false),
+		// choices are shown in a pseudo-random order, the same for the whole session or different for each player
+		field.Enum("shuffleChoices").Values("none", "session", "player").Default("none"),
+		// the number of questions drawn into the pool of the session, zero if all questions of the game are asked
+		field.Int("poolSize").NonNegative().Default(0),
+		// the pool has been drawn evenly from the categories of questions
+		field.Bool("poolByCategory").Default(false),
 	}
 }
 
@@ -39,5 +60,15 @@ func (Session) Edges() []ent.Edge {
 			Annotations(entsql.Annotation{
 				OnDelete: entsql.Cascade,
 			}),
+		edge.To("bans", Ban.Type).
+			Annotations(entsql.Annotation{
+				OnDelete: entsql.Cascade,
+			}),
+		edge.To("teams", Team.Type).
+			Annotations(entsql.Annotation{
+				OnDelete: entsql.Cascade,
+			}),
+		// the questions asked in the session if its pool size is set
+		edge.To("pool", Question.Type),
 	}
 }
@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"github.com/google/uuid"
+	"regexp"
+)
+
+type Team struct {
+	ent.Schema
+}
+
+func (Team) Fields() []ent.Field {
+	return []ent.Field{
+		field.UUID("id", uuid.Nil).Immutable(),
+		field.Text("name").MaxLen(64).MinLen(1),
+		field.String("colour").Match(regexp.MustCompile("^#[0-9a-f]{6}$")), // CSS hex colour
+		field.Int("order"),
+	}
+}
+
+func (Team) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("name").Edges("session").Unique(),
+		index.Fields("order").Edges("session").Unique(),
+	}
+}
+
+func (Team) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("session", Session.Type).
+			Ref("teams").
+			Unique().
+			Required(),
+		edge.To("members", Player.Type),
+	}
+}
@@ -4,22 +4,35 @@ package model
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"math"
+	"math/rand"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 	"vkane.cz/tinyquiz/pkg/codeGenerator"
 	"vkane.cz/tinyquiz/pkg/gameCreator"
+	"vkane.cz/tinyquiz/pkg/markdown"
 	"vkane.cz/tinyquiz/pkg/model/ent"
 	"vkane.cz/tinyquiz/pkg/model/ent/answer"
 	"vkane.cz/tinyquiz/pkg/model/ent/askedquestion"
+	"vkane.cz/tinyquiz/pkg/model/ent/ban"
 	"vkane.cz/tinyquiz/pkg/model/ent/choice"
 	"vkane.cz/tinyquiz/pkg/model/ent/game"
 	"vkane.cz/tinyquiz/pkg/model/ent/player"
+	"vkane.cz/tinyquiz/pkg/model/ent/predicate"
 	"vkane.cz/tinyquiz/pkg/model/ent/question"
+	"vkane.cz/tinyquiz/pkg/model/ent/round"
 	"vkane.cz/tinyquiz/pkg/model/ent/session"
+	"vkane.cz/tinyquiz/pkg/model/ent/team"
 	"vkane.cz/tinyquiz/pkg/rtcomm"
+	"vkane.cz/tinyquiz/pkg/textMatch"
 )
 
 var NoSuchEntity = errors.New("no such entity found")
@@ -41,7 +54,7 @@ type Stats struct {
 
 func (m *Model) GetStats(c context.Context) (Stats, error) {
 	var s Stats
-	if games, err := m.c.Game.Query().Count(c); err == nil {
+	if games, err := m.c.Game.Query().Where(game.Not(game.HasNext())).Count(c); err == nil {
 		s.Games = uint64(games)
 	} else {
 		return s, err
@@ -63,7 +76,10 @@ func (m *Model) GetStats(c context.Context) (Stats, error) {
 
 // returns the player's UUID if error is nil
 // err = NoSuchEntity if the sessionCode is incorrect
-func (m *Model) RegisterPlayer(playerName string, sessionCode string, now time.Time, c context.Context) (*ent.Player, error) {
+// err = Banned if the name has been banned from the session
+// err = NoSuchTeam if the session is in team mode and has no team of the teamName
+// In team mode, the player joins the team of the teamName or the smallest team if teamName is empty.
+func (m *Model) RegisterPlayer(playerName string, teamName string, sessionCode string, now time.Time, c context.Context) (*ent.Player, error) {
 	tx, err := m.c.BeginTx(c, &sql.TxOptions{
 		Isolation: sql.LevelRepeatableRead,
 	})
@@ -77,7 +93,18 @@ func (m *Model) RegisterPlayer(playerName string, sessionCode string, now time.T
 	} else if err != nil {
 		return nil, err
 	} else {
-		if p, err := tx.Player.Create().SetID(uuid.New()).SetJoined(now).SetName(playerName).SetSession(s).Save(c); err == nil {
+		if banned, err := tx.Ban.Query().Where(ban.HasSessionWith(session.ID(s.ID)), ban.NameEqualFold(playerName)).Exist(c); err != nil {
+			return nil, err
+		} else if banned {
+			return nil, Banned
+		}
+		var playerCreate = tx.Player.Create().SetID(uuid.New()).SetJoined(now).SetName(playerName).SetSession(s)
+		if t, err := pickTeam(tx, s.ID, teamName, c); err != nil {
+			return nil, err
+		} else if t != nil {
+			playerCreate.SetTeam(t)
+		}
+		if p, err := playerCreate.Save(c); err == nil {
 			return p, nil
 		} else if ent.IsConstraintError(err) {
 			return nil, ConstraintViolation
@@ -87,7 +114,65 @@ func (m *Model) RegisterPlayer(playerName string, sessionCode string, now time.T
 	}
 }
 
-func (m *Model) CreateSession(organiserName string, gameCode string, now time.Time, c context.Context) (*ent.Session, *ent.Player, error) {
+var NoSuchTeam = errors.New("there is no team of this name in the session")
+
+// Returns the team of the given name or the team with the fewest players if the name is empty.
+// Returns nil if the session is not in team mode.
+func pickTeam(tx *ent.Tx, sessionId uuid.UUID, name string, c context.Context) (*ent.Team, error) {
+	teams, err := tx.Team.Query().Where(team.HasSessionWith(session.ID(sessionId))).Order(ent.Asc(team.FieldOrder)).WithMembers(func(q *ent.PlayerQuery) {
+		q.Where(player.KickedIsNil())
+	}).All(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, nil
+	}
+
+	if name != "" {
+		for _, t := range teams {
+			if strings.EqualFold(t.Name, name) {
+				return t, nil
+			}
+		}
+		return nil, NoSuchTeam
+	}
+	var smallest = teams[0]
+	for _, t := range teams[1:] {
+		if len(t.Edges.Members) < len(smallest.Edges.Members) {
+			smallest = t
+		}
+	}
+	return smallest, nil
+}
+
+type SessionOptions struct {
+	Scoring     session.Scoring // defaults to session.ScoringSimple if left empty
+	AutoAdvance bool
+	Autopilot   bool
+	BreakLength time.Duration       // used by autopilot only
+	MinPlayers  uint64              // used by autopilot only, defaults to 1 if left zero
+	Teams       []TeamOptions       // the session is in team mode if there are any
+	TeamScoring session.TeamScoring // defaults to session.TeamScoringSum if left empty
+	SelfPaced   bool
+	Deadline    time.Time // used by self-paced sessions only, zero if the session stays open until the organiser finishes it
+	// multiple-choice answers score partially instead of all-or-nothing
+	PartialCredit bool
+	// questions are asked in a pseudo-random order, the same for the whole session
+	ShuffleQuestions bool
+	ShuffleChoices   session.ShuffleChoices // defaults to session.ShuffleChoicesNone if left empty
+	// the number of questions drawn at random into the pool of the session, zero if all questions are asked
+	PoolSize int
+	// the pool is drawn evenly from the categories of questions
+	PoolByCategory bool
+}
+
+type TeamOptions struct {
+	Name   string
+	Colour string // CSS hex colour, e.g. #1e88e5
+}
+
+func (m *Model) CreateSession(organiserName string, gameCode string, options SessionOptions, now time.Time, c context.Context) (*ent.Session, *ent.Player, error) {
 	tx, err := m.c.BeginTx(c, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -96,10 +181,43 @@ func (m *Model) CreateSession(organiserName string, gameCode string, now time.Ti
 	}
 	defer tx.Rollback()
 
-	if gameId, err := tx.Game.Query().Where(game.CodeEqualFold(gameCode)).OnlyID(c); err == nil {
+	// earlier versions of games are kept for their results only
+	if gameId, err := tx.Game.Query().Where(game.CodeEqualFold(gameCode), game.Not(game.HasNext())).OnlyID(c); err == nil {
 		if incremental, err := m.getCodeIncremental(c); err == nil {
 			if code, err := codeGenerator.GenerateRandomCode(incremental, codeRandomPartLength); err == nil {
-				if s, err := tx.Session.Create().SetID(uuid.New()).SetCreated(now).SetCode(string(code)).SetGameID(gameId).Save(c); err == nil {
+				var sessionCreate = tx.Session.Create().SetID(uuid.New()).SetCreated(now).SetCode(string(code)).SetGameID(gameId).SetAutoAdvance(options.AutoAdvance).SetAutopilot(options.Autopilot).SetBreakLength(uint64(options.BreakLength.Milliseconds())).SetPartialCredit(options.PartialCredit).SetShuffleQuestions(options.ShuffleQuestions)
+				if options.Scoring != "" {
+					sessionCreate.SetScoring(options.Scoring)
+				}
+				if options.MinPlayers > 0 {
+					sessionCreate.SetMinPlayers(options.MinPlayers)
+				}
+				if options.TeamScoring != "" {
+					sessionCreate.SetTeamScoring(options.TeamScoring)
+				}
+				if options.ShuffleChoices != "" {
+					sessionCreate.SetShuffleChoices(options.ShuffleChoices)
+				}
+				if options.PoolSize > 0 {
+					if questions, err := tx.Question.Query().Where(question.HasGameWith(game.ID(gameId))).Order(ent.Asc(question.FieldOrder)).All(c); err == nil {
+						var pool = drawPool(questions, options.PoolSize, options.PoolByCategory, rand.New(rand.NewSource(time.Now().UnixNano())))
+						sessionCreate.SetPoolSize(len(pool)).SetPoolByCategory(options.PoolByCategory).AddPool(pool...)
+					} else {
+						return nil, nil, err
+					}
+				}
+				if options.SelfPaced {
+					sessionCreate.SetSelfPaced(true)
+					if !options.Deadline.IsZero() {
+						sessionCreate.SetDeadline(options.Deadline)
+					}
+				}
+				if s, err := sessionCreate.Save(c); err == nil {
+					for i, t := range options.Teams {
+						if _, err := tx.Team.Create().SetID(uuid.New()).SetName(t.Name).SetColour(t.Colour).SetOrder(i).SetSession(s).Save(c); err != nil {
+							return nil, nil, err
+						}
+					}
 					if p, err := tx.Player.Create().SetID(uuid.New()).SetJoined(now).SetName(organiserName).SetSession(s).SetOrganiser(true).Save(c); err == nil {
 						err := tx.Commit()
 						return s, p, err
@@ -122,6 +240,40 @@ func (m *Model) CreateSession(organiserName string, gameCode string, now time.Ti
 	}
 }
 
+// Draws size questions at random, or all of them if there are not enough.
+// If byCategory is set, each category gets the same share of the questions as long as it has enough of them.
+func drawPool(questions []*ent.Question, size int, byCategory bool, r *rand.Rand) []*ent.Question {
+	var categories []string
+	var groups = make(map[string][]*ent.Question)
+	for _, q := range questions {
+		var category string
+		if byCategory {
+			category = q.Category
+		}
+		if _, ok := groups[category]; !ok {
+			categories = append(categories, category)
+		}
+		groups[category] = append(groups[category], q)
+	}
+	for _, group := range groups {
+		r.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+	}
+	// the categories getting an extra question, if the size is not divisible, are chosen at random as well
+	r.Shuffle(len(categories), func(i, j int) { categories[i], categories[j] = categories[j], categories[i] })
+
+	var pool = make([]*ent.Question, 0, size)
+	for round, drawn := 0, true; drawn && len(pool) < size; round++ {
+		drawn = false
+		for _, category := range categories {
+			if round < len(groups[category]) && len(pool) < size {
+				pool = append(pool, groups[category][round])
+				drawn = true
+			}
+		}
+	}
+	return pool
+}
+
 func (m *Model) GetPlayerWithSessionAndGame(uid uuid.UUID, c context.Context) (*ent.Player, error) {
 	tx, err := m.c.BeginTx(c, &sql.TxOptions{
 		Isolation: sql.LevelRepeatableRead,
@@ -153,14 +305,21 @@ func (m *Model) GetPlayersStateUpdate(sessionId uuid.UUID, c context.Context) (r
 	}
 	defer tx.Commit()
 
-	if players, err := tx.Player.Query().Where(player.HasSessionWith(session.ID(sessionId))).Order(ent.Asc(player.FieldJoined)).All(c); err == nil {
+	if players, err := tx.Player.Query().Where(player.HasSessionWith(session.ID(sessionId)), player.KickedIsNil()).Order(ent.Asc(player.FieldJoined)).WithTeam().All(c); err == nil {
 		var su rtcomm.StateUpdate
 		su.Players = make([]rtcomm.Player, 0, len(players))
 		for i := 0; i < len(players); i++ {
-			su.Players = append(su.Players, rtcomm.Player{
+			var p = rtcomm.Player{
 				Organiser: players[i].Organiser,
 				Name:      players[i].Name,
-			})
+			}
+			if t := players[i].Edges.Team; t != nil {
+				p.Team = &rtcomm.Team{
+					Name:   t.Name,
+					Colour: t.Colour,
+				}
+			}
+			su.Players = append(su.Players, p)
 		}
 		return su, nil
 	} else {
@@ -168,6 +327,75 @@ func (m *Model) GetPlayersStateUpdate(sessionId uuid.UUID, c context.Context) (r
 	}
 }
 
+var Kicked = errors.New("the player has been removed from the session")
+var Banned = errors.New("the player name has been banned from the session")
+
+// Removes the player of the given name from the organiser's session, so that they disappear from the roster and cannot answer anymore.
+// Their answers either keep counting towards the results under a numbered name, which frees the original one, or get dropped along with the player.
+// If banName is set, nobody can join the session under that name again.
+// Organisers cannot be removed.
+func (m *Model) KickPlayer(organiserId uuid.UUID, name string, keepAnswers bool, banName bool, now time.Time, c context.Context) (*ent.Player, error) {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeOrganiser(tx, organiserId, c)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := tx.Player.Query().Where(player.HasSessionWith(session.ID(sessionId)), player.Name(name), player.KickedIsNil()).Only(c)
+	if ent.IsNotFound(err) {
+		return nil, NoSuchEntity
+	} else if err != nil {
+		return nil, err
+	}
+	if p.Organiser {
+		return nil, Forbidden
+	}
+
+	if keepAnswers {
+		// the player keeps their results under another name, so that the original one can join again
+		if released, err := releasedName(tx, sessionId, p.Name, c); err != nil {
+			return nil, err
+		} else if p, err = p.Update().SetKicked(now).SetName(released).Save(c); err != nil {
+			return nil, err
+		}
+	} else if err := tx.Player.DeleteOne(p).Exec(c); err != nil {
+		return nil, err
+	}
+
+	if banName {
+		if _, err := tx.Ban.Create().SetID(uuid.New()).SetName(name).SetCreated(now).SetSessionID(sessionId).Save(c); err != nil {
+			return nil, err
+		}
+	}
+	return p, tx.Commit()
+}
+
+const maxPlayerNameLength = 64
+
+// Returns the name suffixed by the lowest number not taken in the session, shortened to fit the name length limit
+func releasedName(tx *ent.Tx, sessionId uuid.UUID, name string, c context.Context) (string, error) {
+	for i := 1; ; i++ {
+		var suffix = fmt.Sprintf(" (%d)", i)
+		var base = []rune(name)
+		for len(string(base))+len(suffix) > maxPlayerNameLength {
+			base = base[:len(base)-1]
+		}
+		var released = string(base) + suffix
+		if taken, err := tx.Player.Query().Where(player.HasSessionWith(session.ID(sessionId)), player.Name(released)).Exist(c); err != nil {
+			return "", err
+		} else if !taken {
+			return released, nil
+		}
+	}
+}
+
 func (m *Model) GetQuestionStateUpdate(sessionId uuid.UUID, now time.Time, c context.Context) (rtcomm.StateUpdate, error) {
 	tx, err := m.c.BeginTx(c, &sql.TxOptions{
 		Isolation: sql.LevelRepeatableRead,
@@ -178,27 +406,53 @@ func (m *Model) GetQuestionStateUpdate(sessionId uuid.UUID, now time.Time, c con
 	}
 	defer tx.Commit()
 
-	if aq, err := tx.Question.Query().WithChoices().Where(question.HasAskedWith(askedquestion.HasSessionWith(session.ID(sessionId)))).QueryAsked().WithQuestion(func(q *ent.QuestionQuery) { q.WithChoices() }).Order(ent.Desc(askedquestion.FieldAsked)).First(c); err == nil {
+	s, err := tx.Session.Get(c, sessionId)
+	if ent.IsNotFound(err) {
+		return rtcomm.StateUpdate{}, NoSuchEntity
+	} else if err != nil {
+		return rtcomm.StateUpdate{}, err
+	} else if s.Finished != nil {
+		return rtcomm.StateUpdate{Results: true}, nil
+	}
+
+	if aq, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion(func(q *ent.QuestionQuery) { q.WithChoices(orderChoices).WithRound() }).First(c); err == nil {
 		// either show the current question or hide the old one
 		if aq.Ended == nil {
-			var q = aq.Edges.Question
-			var qu rtcomm.QuestionUpdate
-			qu.Title = q.Title
-			if ends := aq.Asked.Add(time.Duration(q.DefaultLength) * time.Millisecond); !now.Before(ends) {
-				qu.RemainingTime = 0
+			var players []string
+			if s.ShuffleChoices == session.ShuffleChoicesPlayer {
+				if players, err = tx.Player.Query().Where(player.HasSessionWith(session.ID(sessionId)), player.Organiser(false), player.KickedIsNil()).Select(player.FieldName).Strings(c); err != nil {
+					return rtcomm.StateUpdate{}, err
+				}
+			}
+			var qu = getQuestionUpdate(s, aq, players, now)
+			var su = rtcomm.StateUpdate{Question: &qu}
+			if pu, err := getProgress(tx, sessionId, aq.ID, c); err == nil {
+				su.Progress = &pu
 			} else {
-				qu.RemainingTime = uint64(ends.Sub(now).Round(time.Millisecond).Milliseconds())
+				return rtcomm.StateUpdate{}, err
 			}
-			qu.Answers = make([]rtcomm.Answer, 0, len(q.Edges.Choices))
-			for i := 0; i < len(q.Edges.Choices); i++ {
-				qu.Answers = append(qu.Answers, rtcomm.Answer{
-					ID:    q.Edges.Choices[i].ID.String(),
-					Title: q.Edges.Choices[i].Title,
-				})
+			if err := fillVotes(tx, aq, su.Progress, c); err != nil {
+				return rtcomm.StateUpdate{}, err
 			}
-			return rtcomm.StateUpdate{Question: &qu}, nil
+			return su, nil
 		} else {
-			return rtcomm.StateUpdate{Break: &rtcomm.BreakUpdate{}}, nil
+			var bu rtcomm.BreakUpdate
+			if err := fillAnswerStats(tx, aq, &bu, c); err != nil {
+				return rtcomm.StateUpdate{}, err
+			}
+			fillExplanations(aq.Edges.Question, &bu)
+			// polls are not scored and thus do not change the standings
+			if aq.Edges.Question.Type == question.TypePoll {
+				bu.Leaderboard = []rtcomm.Standing{}
+			} else if standings, err := getStandings(tx, sessionId, aq.ID, c); err == nil {
+				bu.Leaderboard = standings
+			} else {
+				return rtcomm.StateUpdate{}, err
+			}
+			if err := fillRounds(tx, s, aq, &bu, c); err != nil {
+				return rtcomm.StateUpdate{}, err
+			}
+			return rtcomm.StateUpdate{Break: &bu}, nil
 		}
 	} else if ent.IsNotFound(err) {
 		// There is simply no current question, which is not an error
@@ -208,6 +462,277 @@ func (m *Model) GetQuestionStateUpdate(sessionId uuid.UUID, now time.Time, c con
 	}
 }
 
+// Describes the asked question aq of the session s, which must have its question loaded including choices and its round.
+// Choices of text questions are the accepted answers and thus are not revealed.
+// Choices are shuffled as set by the session, also for each of the given players if they are shuffled per player.
+// Choices of ordering questions are always shuffled, the same way for each asked question unless set otherwise.
+func getQuestionUpdate(s *ent.Session, aq *ent.AskedQuestion, players []string, now time.Time) rtcomm.QuestionUpdate {
+	var q = aq.Edges.Question
+	var qu rtcomm.QuestionUpdate
+	qu.Title = q.Title
+	qu.TitleHTML = string(markdown.Render(q.Title))
+	qu.TimerUpdate = getTimer(aq, q, now)
+	qu.Type = string(q.Type)
+	qu.Image = ImageURL(q.Image)
+	if q.Type == question.TypePoll {
+		qu.Votes = q.Votes
+	}
+	if q.Edges.Round != nil {
+		qu.Round = q.Edges.Round.Title
+	}
+	qu.Answers = make([]rtcomm.Answer, 0, len(q.Edges.Choices))
+	for i := 0; i < len(q.Edges.Choices) && q.Type != question.TypeText; i++ {
+		qu.Answers = append(qu.Answers, rtcomm.Answer{
+			ID:        q.Edges.Choices[i].ID.String(),
+			Title:     q.Edges.Choices[i].Title,
+			TitleHTML: string(markdown.Render(q.Edges.Choices[i].Title)),
+			Image:     ImageURL(q.Edges.Choices[i].Image),
+		})
+	}
+
+	var ordering = q.Type == question.TypeOrdering
+	if s.ShuffleChoices == session.ShuffleChoicesPlayer {
+		qu.Shuffled = make(map[string][]rtcomm.Answer, len(players))
+		for _, name := range players {
+			qu.Shuffled[name] = shuffleAnswers(qu.Answers, append(s.ID[:], name...), ordering)
+		}
+	}
+	if s.ShuffleChoices != session.ShuffleChoicesNone {
+		qu.Answers = shuffleAnswers(qu.Answers, s.ID[:], ordering)
+	} else if ordering {
+		qu.Answers = shuffleAnswers(qu.Answers, aq.ID[:], ordering)
+	}
+	return qu
+}
+
+// Returns a copy of answers in a pseudo-random order given by the seed.
+// If ordering is set, answers must be in the correct order, which is never returned.
+func shuffleAnswers(answers []rtcomm.Answer, seed []byte, ordering bool) []rtcomm.Answer {
+	var shuffled = make([]rtcomm.Answer, len(answers))
+	copy(shuffled, answers)
+	var keys = make(map[string]uint64, len(answers))
+	for _, a := range answers {
+		keys[a.ID] = shuffleKey(seed, []byte(a.ID))
+	}
+	sort.Slice(shuffled, func(i, j int) bool { return keys[shuffled[i].ID] < keys[shuffled[j].ID] })
+
+	if ordering && len(shuffled) > 1 {
+		var correct = true
+		for i := range shuffled {
+			correct = correct && shuffled[i].ID == answers[i].ID
+		}
+		if correct {
+			shuffled[0], shuffled[1] = shuffled[1], shuffled[0]
+		}
+	}
+	return shuffled
+}
+
+// Returns the sort key of the entity of the given ID in a pseudo-random order given by the seed
+func shuffleKey(seed []byte, id []byte) uint64 {
+	var h = sha256.New()
+	h.Write(seed)
+	h.Write(id)
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+// Returns the URL the image of the given ID is served at, an empty string for uuid.Nil
+func ImageURL(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return "/image/" + id.String()
+}
+
+// Loads choices in the order of the game, which is the correct one for ordering questions
+func orderChoices(q *ent.ChoiceQuery) {
+	q.Order(ent.Asc(choice.FieldOrder))
+}
+
+// Returns the time the asked question aq of the question q stops accepting answers unless it gets paused.
+// It is meaningless while the question is paused.
+func questionDeadline(aq *ent.AskedQuestion, q *ent.Question) time.Time {
+	return aq.Asked.Add(time.Duration(q.DefaultLength+aq.Extension) * time.Millisecond)
+}
+
+// Returns the time left to answer the asked question aq of the question q, negative if the deadline has already passed
+func questionTimeLeft(aq *ent.AskedQuestion, q *ent.Question, now time.Time) time.Duration {
+	if aq.Paused != nil {
+		now = *aq.Paused
+	}
+	return questionDeadline(aq, q).Sub(now)
+}
+
+func getTimer(aq *ent.AskedQuestion, q *ent.Question, now time.Time) rtcomm.TimerUpdate {
+	var tu rtcomm.TimerUpdate
+	tu.Paused = aq.Paused != nil
+	if left := questionTimeLeft(aq, q, now); left > 0 {
+		tu.RemainingTime = uint64(left.Round(time.Millisecond).Milliseconds())
+	}
+	return tu
+}
+
+// Returns a query for asked questions of the session, the current (or the last) one first.
+// Questions asked to individual players in self-paced sessions are left out.
+func queryCurrentAskedQuestion(tx *ent.Tx, sessionId uuid.UUID) *ent.AskedQuestionQuery {
+	return tx.AskedQuestion.Query().Where(askedquestion.HasSessionWith(session.ID(sessionId)), askedquestion.Not(askedquestion.HasPlayer())).Order(ent.Desc(askedquestion.FieldAsked))
+}
+
+// Counts the players, who have already answered the asked question
+func getProgress(tx *ent.Tx, sessionId uuid.UUID, askedQuestionId uuid.UUID, c context.Context) (rtcomm.ProgressUpdate, error) {
+	players, err := tx.Player.Query().Where(player.HasSessionWith(session.ID(sessionId)), player.Organiser(false), player.KickedIsNil()).Order(ent.Asc(player.FieldJoined)).WithAnswers(func(q *ent.AnswerQuery) {
+		q.Where(answer.HasAskedQuestionWith(askedquestion.ID(askedQuestionId)))
+	}).All(c)
+	if err != nil {
+		return rtcomm.ProgressUpdate{}, err
+	}
+
+	var pu rtcomm.ProgressUpdate
+	pu.Players = uint64(len(players))
+	pu.Missing = make([]string, 0, len(players))
+	for _, p := range players {
+		if len(p.Edges.Answers) > 0 {
+			pu.Answered++
+		} else {
+			pu.Missing = append(pu.Missing, p.Name)
+		}
+	}
+	return pu, nil
+}
+
+// Fills in the votes cast so far if the asked question aq, which must have its question loaded including choices, is a poll
+func fillVotes(tx *ent.Tx, aq *ent.AskedQuestion, pu *rtcomm.ProgressUpdate, c context.Context) error {
+	if aq.Edges.Question.Type != question.TypePoll {
+		return nil
+	}
+	var bu rtcomm.BreakUpdate
+	if err := fillAnswerStats(tx, aq, &bu, c); err != nil {
+		return err
+	}
+	pu.Votes = bu.Answers
+	return nil
+}
+
+// Returns the answering progress of the current question or an empty StateUpdate if there is no open question
+func (m *Model) GetProgressStateUpdate(sessionId uuid.UUID, c context.Context) (rtcomm.StateUpdate, error) {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return rtcomm.StateUpdate{}, err
+	}
+	defer tx.Commit()
+
+	if aq, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion(func(q *ent.QuestionQuery) { q.WithChoices(orderChoices) }).First(c); err == nil && aq.Ended == nil {
+		if pu, err := getProgress(tx, sessionId, aq.ID, c); err != nil {
+			return rtcomm.StateUpdate{}, err
+		} else if err := fillVotes(tx, aq, &pu, c); err != nil {
+			return rtcomm.StateUpdate{}, err
+		} else {
+			return rtcomm.StateUpdate{Progress: &pu}, nil
+		}
+	} else if err == nil || ent.IsNotFound(err) {
+		return rtcomm.StateUpdate{}, nil
+	} else {
+		return rtcomm.StateUpdate{}, err
+	}
+}
+
+// Aggregates answers to the asked question aq per choice and fills them in bu.
+// aq must have its question loaded including choices.
+// Sets statistics of all choices and IDs of choices picked by each player.
+// Answers to text questions are counted for each accepted answer they match and each normalised variant typed by players.
+// Number questions get the correct value and all guesses revealed instead.
+// Choices of ordering questions, which must be loaded in order, are counted only if placed correctly.
+// Picks of text questions are normalised variants, picks of number questions are guesses and picks of ordering questions are in the order of the player.
+func fillAnswerStats(tx *ent.Tx, aq *ent.AskedQuestion, bu *rtcomm.BreakUpdate, c context.Context) error {
+	answers, err := tx.Answer.Query().Where(answer.HasAskedQuestionWith(askedquestion.ID(aq.ID))).WithChoice().WithAnswerer().Order(ent.Asc(answer.FieldAnswered)).All(c)
+	if err != nil {
+		return err
+	}
+
+	var q = aq.Edges.Question
+	bu.Title = q.Title
+	bu.TitleHTML = string(markdown.Render(q.Title))
+	bu.Type = string(q.Type)
+	if q.Type == question.TypeNumber && q.Value != nil {
+		bu.Number = &rtcomm.NumberStats{Value: *q.Value, Margin: q.Margin, Guesses: make([]float64, 0, len(answers))}
+	}
+	var counts = make(map[uuid.UUID]uint64)
+	var picks = make(map[string][]string)
+	var variantIndex = make(map[string]int)
+	for _, a := range answers {
+		if q.Type == question.TypeText {
+			var text = textMatch.Normalise(a.Text)
+			if i, ok := variantIndex[text]; ok {
+				bu.Variants[i].Count++
+			} else {
+				variantIndex[text] = len(bu.Variants)
+				bu.Variants = append(bu.Variants, rtcomm.VariantStats{Text: text, Correct: textAnswerCorrect(q, a), Count: 1})
+			}
+			for _, ch := range q.Edges.Choices {
+				if textMatch.Matches(a.Text, ch.Title, uint(q.Tolerance)) {
+					counts[ch.ID]++
+				}
+			}
+			picks[a.Edges.Answerer.Name] = []string{text}
+		} else if q.Type == question.TypeOrdering {
+			if a.Position == nil || *a.Position >= len(q.Edges.Choices) {
+				continue
+			}
+			if q.Edges.Choices[*a.Position].ID == a.Edges.Choice.ID {
+				counts[a.Edges.Choice.ID]++
+			}
+			if picks[a.Edges.Answerer.Name] == nil {
+				picks[a.Edges.Answerer.Name] = make([]string, len(q.Edges.Choices))
+			}
+			picks[a.Edges.Answerer.Name][*a.Position] = a.Edges.Choice.ID.String()
+		} else if q.Type == question.TypeNumber {
+			if bu.Number != nil && a.Number != nil {
+				bu.Number.Guesses = append(bu.Number.Guesses, *a.Number)
+				picks[a.Edges.Answerer.Name] = []string{strconv.FormatFloat(*a.Number, 'f', -1, 64)}
+			}
+		} else {
+			counts[a.Edges.Choice.ID]++
+			picks[a.Edges.Answerer.Name] = append(picks[a.Edges.Answerer.Name], a.Edges.Choice.ID.String())
+		}
+	}
+	sort.SliceStable(bu.Variants, func(i, j int) bool { return bu.Variants[i].Count > bu.Variants[j].Count })
+	if bu.Number != nil {
+		sort.Float64s(bu.Number.Guesses)
+	}
+	bu.Picks = picks
+
+	bu.Answers = make([]rtcomm.AnswerStats, 0, len(q.Edges.Choices))
+	for _, ch := range q.Edges.Choices {
+		bu.Answers = append(bu.Answers, rtcomm.AnswerStats{
+			Answer: rtcomm.Answer{
+				ID:        ch.ID.String(),
+				Title:     ch.Title,
+				TitleHTML: string(markdown.Render(ch.Title)),
+				Image:     ImageURL(ch.Image),
+			},
+			Correct: ch.Correct && q.Type != question.TypePoll, // polls have no correct answer
+			Count:   counts[ch.ID],
+		})
+	}
+	return nil
+}
+
+// Fills in the explanation of the question q and the feedback on its choices, which must be loaded in the order of bu.Answers.
+// They are revealed in the break only, unlike the rest of the statistics, which may be shown while the question is open.
+func fillExplanations(q *ent.Question, bu *rtcomm.BreakUpdate) {
+	if q.Explanation != "" {
+		bu.ExplanationHTML = string(markdown.Render(q.Explanation))
+	}
+	for i, ch := range q.Edges.Choices {
+		if ch.Feedback != "" && i < len(bu.Answers) {
+			bu.Answers[i].FeedbackHTML = string(markdown.Render(ch.Feedback))
+		}
+	}
+}
+
 //TODO reuse transaction
 func (m *Model) GetFullStateUpdate(sessionId uuid.UUID, now time.Time, c context.Context) (rtcomm.StateUpdate, error) {
 	su, err := m.GetPlayersStateUpdate(sessionId, c)
@@ -216,6 +741,9 @@ func (m *Model) GetFullStateUpdate(sessionId uuid.UUID, now time.Time, c context
 	}
 	if su2, err := m.GetQuestionStateUpdate(sessionId, now, c); err == nil {
 		su.Question = su2.Question
+		su.Progress = su2.Progress
+		su.Break = su2.Break
+		su.Results = su2.Results
 	} else {
 		return rtcomm.StateUpdate{}, err
 	}
@@ -225,8 +753,7 @@ func (m *Model) GetFullStateUpdate(sessionId uuid.UUID, now time.Time, c context
 var NoNextQuestion = errors.New("there is no next question") // TODO fill
 
 // TODO retry on serialization failure
-// TODO validate sessionId
-func (m *Model) NextQuestion(sessionId uuid.UUID, now time.Time, c context.Context) error {
+func (m *Model) NextQuestion(organiserId uuid.UUID, now time.Time, c context.Context) error {
 	tx, err := m.c.BeginTx(c, &sql.TxOptions{
 		Isolation: sql.LevelSerializable,
 	})
@@ -236,14 +763,13 @@ func (m *Model) NextQuestion(sessionId uuid.UUID, now time.Time, c context.Conte
 	// TODO rollback only if not yet committed
 	defer tx.Rollback()
 
-	if err := tx.Session.Update().Where(session.ID(sessionId)).Where(session.StartedIsNil()).SetStarted(now).Exec(c); err != nil {
+	sessionId, err := authorizeLiveOrganiser(tx, organiserId, c)
+	if err != nil {
 		return err
 	}
 
-	var query = tx.Question.Query().Where(question.HasGameWith(game.HasSessionsWith(session.ID(sessionId)))).Order(ent.Asc(question.FieldOrder))
-
-	if current, err := tx.AskedQuestion.Query().Where(askedquestion.HasSessionWith(session.ID(sessionId))).WithQuestion().Order(ent.Desc(askedquestion.FieldAsked)).First(c); err == nil {
-		query.Where(question.OrderGT(current.Edges.Question.Order))
+	current, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion().First(c)
+	if err == nil {
 		if current.Ended == nil {
 			if _, err := current.Update().SetEnded(now).Save(c); err == nil {
 				return tx.Commit()
@@ -251,141 +777,1467 @@ func (m *Model) NextQuestion(sessionId uuid.UUID, now time.Time, c context.Conte
 				return err
 			}
 		}
-	} else if !ent.IsNotFound(err) {
+	} else if ent.IsNotFound(err) {
+		current = nil
+	} else {
 		return err
 	}
 
-	if next, err := query.First(c); err == nil {
-		if _, err := tx.AskedQuestion.Create().SetID(uuid.New()).SetAsked(now).SetSessionID(sessionId).SetQuestion(next).Save(c); err != nil {
+	var after *ent.Question
+	if current != nil {
+		after = current.Edges.Question
+	}
+	return askNextQuestionOrFinish(tx, sessionId, after, now, c)
+}
+
+// Asks the question following the question after (see askNextQuestion) and commits tx.
+// If there is no such question, the session gets finished and NoNextQuestion is returned.
+func askNextQuestionOrFinish(tx *ent.Tx, sessionId uuid.UUID, after *ent.Question, now time.Time, c context.Context) error {
+	if _, err := askNextQuestion(tx, sessionId, after, now, c); errors.Is(err, NoNextQuestion) {
+		if err := finishSession(tx, sessionId, now, c); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
 			return err
 		}
-	} else if ent.IsNotFound(err) {
 		return NoNextQuestion
-	} else {
+	} else if err != nil {
 		return err
 	}
+	return tx.Commit()
+}
 
-	tx.Commit()
-	return nil
+// Returns a predicate of the questions asked in the session s,
+// which are those drawn into its pool if it has one, or all questions of its game otherwise
+func inSession(s *ent.Session) predicate.Question {
+	if s.PoolSize > 0 {
+		return question.HasPoolsWith(session.ID(s.ID))
+	}
+	return question.HasGameWith(game.HasSessionsWith(session.ID(s.ID)))
 }
 
-var QuestionClosed = errors.New("the deadline for answers to this question has passed")
-var AlreadyAnswered = errors.New("the player has already answered the question")
+// Returns the questions asked in the session s in their order, including their rounds.
+// Questions follow the game's order unless the session shuffles them, then the order is given by the session's ID.
+// Shuffled questions stay in their rounds.
+func querySessionQuestions(tx *ent.Tx, s *ent.Session, c context.Context) ([]*ent.Question, error) {
+	questions, err := tx.Question.Query().Where(inSession(s)).Order(ent.Asc(question.FieldOrder)).WithRound().All(c)
+	if err != nil {
+		return nil, err
+	}
+	if s.ShuffleQuestions {
+		var keys = make(map[uuid.UUID]uint64, len(questions))
+		for _, q := range questions {
+			keys[q.ID] = shuffleKey(s.ID[:], q.ID[:])
+		}
+		// questions without a round precede the first one
+		var round = func(q *ent.Question) int {
+			if q.Edges.Round == nil {
+				return 0
+			}
+			return q.Edges.Round.Order
+		}
+		sort.Slice(questions, func(i, j int) bool {
+			if ri, rj := round(questions[i]), round(questions[j]); ri != rj {
+				return ri < rj
+			}
+			return keys[questions[i].ID] < keys[questions[j].ID]
+		})
+	}
+	return questions, nil
+}
 
-func (m *Model) SaveAnswer(playerId uuid.UUID, choiceId uuid.UUID, now time.Time, c context.Context) (*ent.Answer, error) {
+// Returns the questions asked in the session in their order, see querySessionQuestions
+func (m *Model) GetSessionQuestions(sessionId uuid.UUID, c context.Context) ([]*ent.Question, error) {
 	tx, err := m.c.BeginTx(c, &sql.TxOptions{
-		Isolation: sql.LevelSerializable,
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
 	})
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback()
+	defer tx.Commit()
 
-	// check whether the player could pick this choice
-	if exists, err := tx.Choice.Query().Where(choice.HasQuestionWith(question.HasGameWith(game.HasSessionsWith(session.HasPlayersWith(player.ID(playerId))))), choice.ID(choiceId)).Exist(c); err == nil && !exists {
+	s, err := tx.Session.Get(c, sessionId)
+	if ent.IsNotFound(err) {
 		return nil, NoSuchEntity
 	} else if err != nil {
 		return nil, err
 	}
+	return querySessionQuestions(tx, s, c)
+}
 
-	var q *ent.Question
-	// find the most recent question
-	if q, err = tx.Player.Query().Where(player.ID(playerId)).QuerySession().QueryAskedQuestions().QueryQuestion().WithAsked(func(q *ent.AskedQuestionQuery) {
-		q.Where(askedquestion.HasSessionWith(session.HasPlayersWith(player.ID(playerId))))
-	}).Order(ent.Desc(question.FieldOrder)).First(c); ent.IsNotFound(err) {
-		return nil, NoSuchEntity
-	} else if err != nil {
+// Returns the question of the session following the question after in the session's order, or the first one if after is nil.
+// Returns NoNextQuestion if after is the last question.
+func nextQuestion(tx *ent.Tx, sessionId uuid.UUID, after *ent.Question, c context.Context) (*ent.Question, error) {
+	s, err := tx.Session.Get(c, sessionId)
+	if err != nil {
 		return nil, err
 	}
-
-	// check if the question is open
-	// Asked[0] is guaranteed to exist thanks to the previous query
-	if q.Edges.Asked[0].Ended != nil || q.Edges.Asked[0].Asked.Add(time.Duration(q.DefaultLength)*time.Millisecond).Before(now) {
-		return nil, QuestionClosed
+	questions, err := querySessionQuestions(tx, s, c)
+	if err != nil {
+		return nil, err
 	}
 
-	// check the player has not answered yet
-	if exists, err := tx.Answer.Query().Where(answer.HasAnswererWith(player.ID(playerId)), answer.HasChoiceWith(choice.HasQuestionWith(question.ID(q.ID)))).Exist(c); err != nil {
-		return nil, err
-	} else if exists {
-		return nil, AlreadyAnswered
+	var i int
+	if after != nil {
+		for i < len(questions) && questions[i].ID != after.ID {
+			i++
+		}
+		i++
+	}
+	if i >= len(questions) {
+		return nil, NoNextQuestion
 	}
+	return questions[i], nil
+}
 
-	if a, err := tx.Answer.Create().SetID(uuid.New()).SetAnswered(now).SetChoiceID(choiceId).SetAnswererID(playerId).Save(c); err == nil {
-		tx.Commit()
-		return a, nil
+// Asks the question following the question after in the session's order, or the first one if after is nil.
+// Returns the new asked question including its question.
+func askNextQuestion(tx *ent.Tx, sessionId uuid.UUID, after *ent.Question, now time.Time, c context.Context) (*ent.AskedQuestion, error) {
+	if next, err := nextQuestion(tx, sessionId, after, c); err == nil {
+		return askQuestion(tx, sessionId, next, now, c)
 	} else {
 		return nil, err
 	}
 }
 
-type PlayerResult struct {
-	Player  *ent.Player
-	place   uint64
-	correct int64
+// Asks the question q, starting the session if needed. A finished session continues.
+// Returns the new asked question including its question.
+func askQuestion(tx *ent.Tx, sessionId uuid.UUID, q *ent.Question, now time.Time, c context.Context) (*ent.AskedQuestion, error) {
+	if err := tx.Session.Update().Where(session.ID(sessionId)).Where(session.StartedIsNil()).SetStarted(now).Exec(c); err != nil {
+		return nil, err
+	}
+	if err := tx.Session.Update().Where(session.ID(sessionId)).ClearFinished().Exec(c); err != nil {
+		return nil, err
+	}
+
+	if aq, err := tx.AskedQuestion.Create().SetID(uuid.New()).SetAsked(now).SetSessionID(sessionId).SetQuestion(q).Save(c); err == nil {
+		aq.Edges.Question = q
+		return aq, nil
+	} else {
+		return nil, err
+	}
 }
 
-func (r PlayerResult) Points() int64 {
-	return r.correct
+// Closes the current question of the session if it is open.
+// Returns the current (or the last) asked question including its question, nil if no question has been asked yet.
+func closeCurrentQuestion(tx *ent.Tx, sessionId uuid.UUID, now time.Time, c context.Context) (*ent.AskedQuestion, error) {
+	if current, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion().First(c); err == nil {
+		if current.Ended == nil {
+			if err := current.Update().SetEnded(now).Exec(c); err != nil {
+				return nil, err
+			}
+		}
+		return current, nil
+	} else if ent.IsNotFound(err) {
+		return nil, nil
+	} else {
+		return nil, err
+	}
 }
 
-func (r PlayerResult) Place() uint64 {
+// Voids the open question, so that it does not count towards the results, and asks the next one.
+// If there is no open question, the question, which would be asked next, is skipped instead.
+func (m *Model) SkipQuestion(organiserId uuid.UUID, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeLiveOrganiser(tx, organiserId, c)
+	if err != nil {
+		return err
+	}
+
+	var after *ent.Question
+	if current, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion().First(c); err == nil {
+		after = current.Edges.Question
+		if current.Ended == nil {
+			if err := current.Update().SetEnded(now).SetSkipped(true).Exec(c); err != nil {
+				return err
+			}
+			return askNextQuestionOrFinish(tx, sessionId, after, now, c)
+		}
+	} else if !ent.IsNotFound(err) {
+		return err
+	}
+
+	if upcoming, err := nextQuestion(tx, sessionId, after, c); err == nil {
+		after = upcoming
+	} else if !errors.Is(err, NoNextQuestion) {
+		return err
+	}
+	return askNextQuestionOrFinish(tx, sessionId, after, now, c)
+}
+
+// Asks the current (or the last) question again. Only answers to the last attempt count towards the results.
+func (m *Model) RepeatQuestion(organiserId uuid.UUID, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeLiveOrganiser(tx, organiserId, c)
+	if err != nil {
+		return err
+	}
+
+	if current, err := closeCurrentQuestion(tx, sessionId, now, c); err != nil {
+		return err
+	} else if current == nil {
+		return NoSuchEntity
+	} else if _, err := askQuestion(tx, sessionId, current.Edges.Question, now, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Closes the open question if any and asks the question of the given ID, which must be one of the session's questions.
+// The session then continues with the questions following it.
+func (m *Model) AskQuestion(organiserId uuid.UUID, questionId uuid.UUID, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeLiveOrganiser(tx, organiserId, c)
+	if err != nil {
+		return err
+	}
+
+	s, err := tx.Session.Get(c, sessionId)
+	if err != nil {
+		return err
+	}
+	q, err := tx.Question.Query().Where(question.ID(questionId), inSession(s)).Only(c)
+	if ent.IsNotFound(err) {
+		return NoSuchEntity
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := closeCurrentQuestion(tx, sessionId, now, c); err != nil {
+		return err
+	}
+	if _, err := askQuestion(tx, sessionId, q, now, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Marks the session as finished unless it already is
+func finishSession(tx *ent.Tx, sessionId uuid.UUID, now time.Time, c context.Context) error {
+	return tx.Session.Update().Where(session.ID(sessionId), session.FinishedIsNil()).SetFinished(now).Exec(c)
+}
+
+// Closes the open question if any and finishes the session, e.g. a self-paced one before its deadline
+func (m *Model) FinishSession(organiserId uuid.UUID, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeOrganiser(tx, organiserId, c)
+	if err != nil {
+		return err
+	}
+
+	if _, err := closeCurrentQuestion(tx, sessionId, now, c); err != nil {
+		return err
+	}
+	if err := finishSession(tx, sessionId, now, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Returns the open question of the session, which has not run out of time yet, including its question
+func getOpenQuestion(tx *ent.Tx, sessionId uuid.UUID, now time.Time, c context.Context) (*ent.AskedQuestion, error) {
+	if aq, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion().First(c); err == nil {
+		if aq.Ended != nil || questionTimeLeft(aq, aq.Edges.Question, now) < 0 {
+			return nil, QuestionClosed
+		}
+		return aq, nil
+	} else if ent.IsNotFound(err) {
+		return nil, QuestionClosed
+	} else {
+		return nil, err
+	}
+}
+
+// Stops the countdown of the current question. Pausing a paused question does nothing.
+func (m *Model) PauseQuestion(organiserId uuid.UUID, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeLiveOrganiser(tx, organiserId, c)
+	if err != nil {
+		return err
+	}
+
+	aq, err := getOpenQuestion(tx, sessionId, now, c)
+	if err != nil {
+		return err
+	}
+	if aq.Paused == nil {
+		if err := aq.Update().SetPaused(now).Exec(c); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Continues the countdown of the current question, the pause does not count towards its length.
+// Resuming a running question does nothing.
+func (m *Model) ResumeQuestion(organiserId uuid.UUID, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeLiveOrganiser(tx, organiserId, c)
+	if err != nil {
+		return err
+	}
+
+	aq, err := getOpenQuestion(tx, sessionId, now, c)
+	if err != nil {
+		return err
+	}
+	if aq.Paused != nil {
+		var pause = now.Sub(*aq.Paused)
+		if pause < 0 {
+			pause = 0
+		}
+		if err := aq.Update().ClearPaused().AddExtension(uint64(pause.Milliseconds())).Exec(c); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Gives players more time to answer the current question
+func (m *Model) ExtendQuestion(organiserId uuid.UUID, by time.Duration, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeLiveOrganiser(tx, organiserId, c)
+	if err != nil {
+		return err
+	}
+
+	aq, err := getOpenQuestion(tx, sessionId, now, c)
+	if err != nil {
+		return err
+	}
+	if err := aq.Update().AddExtension(uint64(by.Milliseconds())).Exec(c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Accepts answers to the last question, which must be a text question the session is having a break after, that match the variant once normalised.
+// Returns NoSuchEntity if there are no such answers.
+func (m *Model) AcceptAnswer(organiserId uuid.UUID, variant string, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sessionId, err := authorizeLiveOrganiser(tx, organiserId, c)
+	if err != nil {
+		return err
+	}
+
+	aq, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion().First(c)
+	if ent.IsNotFound(err) {
+		return NoSuchEntity
+	} else if err != nil {
+		return err
+	} else if aq.Ended == nil || aq.Edges.Question.Type != question.TypeText {
+		return NoSuchEntity
+	}
+	answers, err := aq.QueryAnswers().Where(answer.Accepted(false)).All(c)
+	if err != nil {
+		return err
+	}
+	var ids = make([]uuid.UUID, 0, len(answers))
+	for _, a := range answers {
+		if textMatch.Normalise(a.Text) == textMatch.Normalise(variant) {
+			ids = append(ids, a.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return NoSuchEntity
+	}
+	if err := tx.Answer.Update().Where(answer.IDIn(ids...)).SetAccepted(true).Exec(c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Returns the corrected countdown of the current question or an empty StateUpdate if there is no open question
+func (m *Model) GetTimerStateUpdate(sessionId uuid.UUID, now time.Time, c context.Context) (rtcomm.StateUpdate, error) {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return rtcomm.StateUpdate{}, err
+	}
+	defer tx.Commit()
+
+	if aq, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion().First(c); err == nil && aq.Ended == nil {
+		var tu = getTimer(aq, aq.Edges.Question, now)
+		return rtcomm.StateUpdate{Timer: &tu}, nil
+	} else if err == nil || ent.IsNotFound(err) {
+		return rtcomm.StateUpdate{}, nil
+	} else {
+		return rtcomm.StateUpdate{}, err
+	}
+}
+
+// Advances the session on its own if it is set up so and it is the right time to do so.
+// Returns whether the session has been advanced and when AutoAdvance shall be called again (zero if there is nothing to wait for).
+func (m *Model) AutoAdvance(sessionId uuid.UUID, now time.Time, c context.Context) (bool, time.Time, error) {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	s, err := tx.Session.Get(c, sessionId)
+	if ent.IsNotFound(err) {
+		return false, time.Time{}, NoSuchEntity
+	} else if err != nil {
+		return false, time.Time{}, err
+	}
+	if s.SelfPaced {
+		// self-paced sessions only get closed at their deadline
+		if s.Deadline == nil || s.Finished != nil {
+			return false, time.Time{}, nil
+		} else if now.Before(*s.Deadline) {
+			return false, *s.Deadline, nil
+		}
+		if err := finishSession(tx, sessionId, now, c); err != nil {
+			return false, time.Time{}, err
+		}
+		return true, time.Time{}, tx.Commit()
+	}
+	if !s.AutoAdvance && !s.Autopilot || s.Finished != nil {
+		return false, time.Time{}, nil
+	}
+	var breakLength = time.Duration(s.BreakLength) * time.Millisecond
+
+	aq, err := queryCurrentAskedQuestion(tx, sessionId).WithQuestion().First(c)
+	if ent.IsNotFound(err) {
+		aq = nil
+	} else if err != nil {
+		return false, time.Time{}, err
+	}
+
+	if aq != nil && aq.Ended == nil {
+		if aq.Paused != nil {
+			return false, time.Time{}, nil
+		}
+		// close the question once its time runs out or everyone has answered
+		if deadline := questionDeadline(aq, aq.Edges.Question); now.Before(deadline) {
+			if pu, err := getProgress(tx, sessionId, aq.ID, c); err != nil {
+				return false, time.Time{}, err
+			} else if pu.Players == 0 || pu.Answered < pu.Players {
+				return false, deadline, nil
+			}
+		}
+		if err := aq.Update().SetEnded(now).Exec(c); err != nil {
+			return false, time.Time{}, err
+		}
+		var next time.Time
+		if s.Autopilot {
+			next = now.Add(breakLength)
+		}
+		return true, next, tx.Commit()
+	}
+
+	if !s.Autopilot {
+		return false, time.Time{}, nil
+	}
+
+	// in autopilot, the first question is asked a break after enough players have joined and the others after a break as well
+	var due time.Time
+	if aq == nil {
+		if last, err := tx.Player.Query().Where(player.HasSessionWith(session.ID(sessionId)), player.Organiser(false), player.KickedIsNil()).Order(ent.Asc(player.FieldJoined)).Offset(int(s.MinPlayers) - 1).First(c); err == nil {
+			due = last.Joined.Add(breakLength)
+		} else if ent.IsNotFound(err) {
+			return false, time.Time{}, nil
+		} else {
+			return false, time.Time{}, err
+		}
+	} else {
+		due = aq.Ended.Add(breakLength)
+	}
+	if now.Before(due) {
+		return false, due, nil
+	}
+
+	var after *ent.Question
+	if aq != nil {
+		after = aq.Edges.Question
+	}
+	if next, err := askNextQuestion(tx, sessionId, after, now, c); err == nil {
+		return true, questionDeadline(next, next.Edges.Question), tx.Commit()
+	} else if errors.Is(err, NoNextQuestion) {
+		if err := finishSession(tx, sessionId, now, c); err != nil {
+			return false, time.Time{}, err
+		}
+		return true, time.Time{}, tx.Commit()
+	} else {
+		return false, time.Time{}, err
+	}
+}
+
+// Returns IDs of sessions, which might need AutoAdvance to be called, e.g. after the server restarts
+func (m *Model) GetAutomatedSessions(c context.Context) ([]uuid.UUID, error) {
+	return m.c.Session.Query().Where(session.FinishedIsNil(), session.Or(
+		session.Autopilot(true),
+		session.And(session.AutoAdvance(true), session.HasAskedQuestionsWith(askedquestion.EndedIsNil())),
+		session.And(session.SelfPaced(true), session.DeadlineNotNil()),
+	)).IDs(c)
+}
+
+var QuestionClosed = errors.New("the deadline for answers to this question has passed")
+var QuestionPaused = errors.New("the question is paused")
+var AlreadyAnswered = errors.New("the player has already answered the question")
+var InvalidAnswer = errors.New("the answer does not suit the question")
+
+func (m *Model) SaveAnswer(playerId uuid.UUID, choiceId uuid.UUID, now time.Time, c context.Context) (*ent.Answer, error) {
+	if answers, err := m.SaveAnswers(playerId, []uuid.UUID{choiceId}, now, c); err == nil {
+		return answers[0], nil
+	} else {
+		return nil, err
+	}
+}
+
+// Saves the set of choices the player has picked as their answer to the current question.
+// Only multiple-choice questions accept more than one choice.
+func (m *Model) SaveAnswers(playerId uuid.UUID, choiceIds []uuid.UUID, now time.Time, c context.Context) ([]*ent.Answer, error) {
+	var unique = make(map[uuid.UUID]bool, len(choiceIds))
+	for _, id := range choiceIds {
+		unique[id] = true
+	}
+	if len(unique) == 0 {
+		return nil, InvalidAnswer
+	}
+	choiceIds = make([]uuid.UUID, 0, len(unique))
+	for id := range unique {
+		choiceIds = append(choiceIds, id)
+	}
+
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// check whether the player could pick these choices
+	if count, err := tx.Choice.Query().Where(choice.HasQuestionWith(question.HasGameWith(game.HasSessionsWith(session.HasPlayersWith(player.ID(playerId))))), choice.IDIn(choiceIds...)).Count(c); err == nil && count != len(choiceIds) {
+		return nil, NoSuchEntity
+	} else if err != nil {
+		return nil, err
+	}
+
+	s, aq, err := getAnswerableQuestion(tx, playerId, now, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the choices belong to the question
+	if count, err := tx.Choice.Query().Where(choice.IDIn(choiceIds...), choice.HasQuestionWith(question.ID(aq.Edges.Question.ID))).Count(c); err != nil {
+		return nil, err
+	} else if count != len(choiceIds) {
+		return nil, QuestionClosed
+	}
+	switch q := aq.Edges.Question; q.Type {
+	case question.TypeSingle:
+		if len(choiceIds) > 1 {
+			return nil, InvalidAnswer
+		}
+	case question.TypeMultiple:
+	case question.TypePoll:
+		if len(choiceIds) > int(q.Votes) {
+			return nil, InvalidAnswer
+		}
+	default:
+		return nil, InvalidAnswer
+	}
+
+	var answersCreate = make([]*ent.AnswerCreate, 0, len(choiceIds))
+	for _, id := range choiceIds {
+		answersCreate = append(answersCreate, tx.Answer.Create().SetID(uuid.New()).SetAnswered(now).SetChoiceID(id).SetAnswererID(playerId).SetAskedQuestion(aq))
+	}
+	if answers, err := tx.Answer.CreateBulk(answersCreate...).Save(c); err == nil {
+		// in self-paced sessions, the question is over for the player once they answer
+		if s.SelfPaced {
+			if err := aq.Update().SetEnded(now).Exec(c); err != nil {
+				return nil, err
+			}
+		}
+		tx.Commit()
+		return answers, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Saves the order the player has put the choices of the current question, which must be an ordering question, in.
+// choiceIds must list all the choices of the question, each once.
+func (m *Model) SaveOrder(playerId uuid.UUID, choiceIds []uuid.UUID, now time.Time, c context.Context) ([]*ent.Answer, error) {
+	var unique = make(map[uuid.UUID]bool, len(choiceIds))
+	for _, id := range choiceIds {
+		unique[id] = true
+	}
+	if len(unique) != len(choiceIds) || len(choiceIds) == 0 {
+		return nil, InvalidAnswer
+	}
+
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// check whether the player could order these choices
+	if count, err := tx.Choice.Query().Where(choice.HasQuestionWith(question.HasGameWith(game.HasSessionsWith(session.HasPlayersWith(player.ID(playerId))))), choice.IDIn(choiceIds...)).Count(c); err == nil && count != len(choiceIds) {
+		return nil, NoSuchEntity
+	} else if err != nil {
+		return nil, err
+	}
+
+	s, aq, err := getAnswerableQuestion(tx, playerId, now, c)
+	if err != nil {
+		return nil, err
+	}
+	if aq.Edges.Question.Type != question.TypeOrdering {
+		return nil, InvalidAnswer
+	}
+
+	// check the choices are exactly those of the question
+	if count, err := tx.Choice.Query().Where(choice.IDIn(choiceIds...), choice.HasQuestionWith(question.ID(aq.Edges.Question.ID))).Count(c); err != nil {
+		return nil, err
+	} else if count != len(choiceIds) {
+		return nil, QuestionClosed
+	}
+	if count, err := aq.Edges.Question.QueryChoices().Count(c); err != nil {
+		return nil, err
+	} else if count != len(choiceIds) {
+		return nil, InvalidAnswer
+	}
+
+	var answersCreate = make([]*ent.AnswerCreate, 0, len(choiceIds))
+	for i, id := range choiceIds {
+		answersCreate = append(answersCreate, tx.Answer.Create().SetID(uuid.New()).SetAnswered(now).SetChoiceID(id).SetPosition(i).SetAnswererID(playerId).SetAskedQuestion(aq))
+	}
+	if answers, err := tx.Answer.CreateBulk(answersCreate...).Save(c); err == nil {
+		// in self-paced sessions, the question is over for the player once they answer
+		if s.SelfPaced {
+			if err := aq.Update().SetEnded(now).Exec(c); err != nil {
+				return nil, err
+			}
+		}
+		tx.Commit()
+		return answers, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Saves the text the player has typed as their answer to the current question, which must be a text question
+func (m *Model) SaveTextAnswer(playerId uuid.UUID, text string, now time.Time, c context.Context) (*ent.Answer, error) {
+	text = strings.TrimSpace(text)
+	if textMatch.Normalise(text) == "" || len(text) > maxTextAnswerLength {
+		return nil, InvalidAnswer
+	}
+
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s, aq, err := getAnswerableQuestion(tx, playerId, now, c)
+	if err != nil {
+		return nil, err
+	}
+	if aq.Edges.Question.Type != question.TypeText {
+		return nil, InvalidAnswer
+	}
+
+	if a, err := tx.Answer.Create().SetID(uuid.New()).SetAnswered(now).SetText(text).SetAnswererID(playerId).SetAskedQuestion(aq).Save(c); err == nil {
+		// in self-paced sessions, the question is over for the player once they answer
+		if s.SelfPaced {
+			if err := aq.Update().SetEnded(now).Exec(c); err != nil {
+				return nil, err
+			}
+		}
+		tx.Commit()
+		return a, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Saves the number the player has guessed as their answer to the current question, which must be a number question
+func (m *Model) SaveNumberAnswer(playerId uuid.UUID, number float64, now time.Time, c context.Context) (*ent.Answer, error) {
+	if math.IsInf(number, 0) || math.IsNaN(number) {
+		return nil, InvalidAnswer
+	}
+
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s, aq, err := getAnswerableQuestion(tx, playerId, now, c)
+	if err != nil {
+		return nil, err
+	}
+	if aq.Edges.Question.Type != question.TypeNumber {
+		return nil, InvalidAnswer
+	}
+
+	if a, err := tx.Answer.Create().SetID(uuid.New()).SetAnswered(now).SetNumber(number).SetAnswererID(playerId).SetAskedQuestion(aq).Save(c); err == nil {
+		// in self-paced sessions, the question is over for the player once they answer
+		if s.SelfPaced {
+			if err := aq.Update().SetEnded(now).Exec(c); err != nil {
+				return nil, err
+			}
+		}
+		tx.Commit()
+		return a, nil
+	} else {
+		return nil, err
+	}
+}
+
+// the limit of the answer text as set by the schema, in bytes
+const maxTextAnswerLength = 256
+
+// Returns the session of the player and the asked question the player can answer now, including its question.
+// That is the current question of the session, or the player's own one in self-paced sessions.
+func getAnswerableQuestion(tx *ent.Tx, playerId uuid.UUID, now time.Time, c context.Context) (*ent.Session, *ent.AskedQuestion, error) {
+	// refuse players removed from the session
+	if kicked, err := tx.Player.Query().Where(player.ID(playerId), player.KickedNotNil()).Exist(c); err != nil {
+		return nil, nil, err
+	} else if kicked {
+		return nil, nil, Kicked
+	}
+
+	s, err := tx.Session.Query().Where(session.HasPlayersWith(player.ID(playerId))).Only(c)
+	if ent.IsNotFound(err) {
+		return nil, nil, NoSuchEntity
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if s.SelfPaced && sessionClosed(s, now) {
+		return nil, nil, QuestionClosed
+	}
+
+	// find the most recent question, which is the player's own one in self-paced sessions
+	var aqQuery *ent.AskedQuestionQuery
+	if s.SelfPaced {
+		aqQuery = queryOwnAskedQuestion(tx, playerId)
+	} else {
+		aqQuery = queryCurrentAskedQuestion(tx, s.ID)
+	}
+	aq, err := aqQuery.WithQuestion().First(c)
+	if ent.IsNotFound(err) {
+		return nil, nil, NoSuchEntity
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// check if the question is open
+	if aq.Ended != nil || questionTimeLeft(aq, aq.Edges.Question, now) < 0 {
+		return nil, nil, QuestionClosed
+	}
+	if aq.Paused != nil {
+		return nil, nil, QuestionPaused
+	}
+
+	// check the player has not answered yet
+	if exists, err := tx.Answer.Query().Where(answer.HasAnswererWith(player.ID(playerId)), answer.HasAskedQuestionWith(askedquestion.ID(aq.ID))).Exist(c); err != nil {
+		return nil, nil, err
+	} else if exists {
+		return nil, nil, AlreadyAnswered
+	}
+	return s, aq, nil
+}
+
+type PlayerResult struct {
+	Player   *ent.Player
+	place    uint64
+	points   float64
+	rounds   map[uuid.UUID]float64 // points gained in each round
+	progress uint64
+}
+
+func (r PlayerResult) Points() float64 {
+	return r.points
+}
+
+// Returns the points gained in the round of the given ID
+func (r PlayerResult) RoundPoints(roundId uuid.UUID) float64 {
+	return r.rounds[roundId]
+}
+
+func (r PlayerResult) Place() uint64 {
 	return r.place
 }
 
-func (m *Model) GetResults(playerId uuid.UUID, c context.Context) ([]PlayerResult, *ent.Session, *ent.Player, error) {
-	tx, err := m.c.BeginTx(c, &sql.TxOptions{
-		Isolation: sql.LevelRepeatableRead,
-		ReadOnly:  true,
-	})
+// Returns the number of questions the player has been asked in a self-paced session
+func (r PlayerResult) Progress() uint64 {
+	return r.progress
+}
+
+type TeamResult struct {
+	Team    *ent.Team
+	Members []*ent.Player
+	place   uint64
+	points  float64
+}
+
+func (r TeamResult) Points() float64 {
+	return r.points
+}
+
+func (r TeamResult) Place() uint64 {
+	return r.place
+}
+
+// Returns the results of all players and, in team mode, of all teams
+func (m *Model) GetResults(playerId uuid.UUID, c context.Context) ([]PlayerResult, []TeamResult, *ent.Session, *ent.Player, error) {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	defer tx.Commit()
+
+	s, err := tx.Session.Query().WithGame().Where(session.HasPlayersWith(player.ID(playerId))).Only(c)
+	if ent.IsNotFound(err) {
+		return nil, nil, nil, nil, NoSuchEntity
+	} else if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	p, err := tx.Player.Query().Where(player.ID(playerId)).Only(c)
+	if ent.IsNotFound(err) {
+		return nil, nil, nil, nil, NoSuchEntity
+	} else if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	scored, err := getScoredAttempts(tx, s.ID, uuid.Nil, c)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	players, err := queryScoredPlayers(tx, s.ID).All(c)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if teams, err := tx.Team.Query().Where(team.HasSessionWith(session.ID(s.ID))).Order(ent.Asc(team.FieldOrder)).All(c); err == nil {
+		var teamResults []TeamResult
+		if len(teams) > 0 {
+			teamResults = computeTeamResults(s, teams, players, scored)
+		}
+		return computeResults(s, players, scored), teamResults, s, p, nil
+	} else {
+		return nil, nil, nil, nil, err
+	}
+}
+
+type PollResult struct {
+	Question *ent.Question
+	Votes    []uint64 // the number of votes for each choice of the question in the order of the game
+	Voters   uint64
+}
+
+// Returns the votes cast in each poll of the session the player takes part in, in the order of the game.
+// Only the last attempt of each poll is counted, unless it has been skipped, see getLastAttempts.
+func (m *Model) GetPollResults(playerId uuid.UUID, c context.Context) ([]PollResult, error) {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	s, err := tx.Session.Query().Where(session.HasPlayersWith(player.ID(playerId))).Only(c)
+	if ent.IsNotFound(err) {
+		return nil, NoSuchEntity
+	} else if err != nil {
+		return nil, err
+	}
+
+	last, err := getLastAttempts(tx, s.ID, uuid.Nil, c)
+	if err != nil {
+		return nil, err
+	}
+	var attempts []uuid.UUID
+	for _, aq := range last {
+		if !aq.Skipped && aq.Edges.Question.Type == question.TypePoll {
+			attempts = append(attempts, aq.ID)
+		}
+	}
+	if len(attempts) == 0 {
+		return nil, nil
+	}
+
+	polls, err := tx.Question.Query().Where(question.HasAskedWith(askedquestion.IDIn(attempts...))).Order(ent.Asc(question.FieldOrder)).WithChoices(orderChoices).All(c)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := tx.Answer.Query().Where(answer.HasAskedQuestionWith(askedquestion.IDIn(attempts...))).WithChoice().WithAnswerer().All(c)
+	if err != nil {
+		return nil, err
+	}
+	var votes = make(map[uuid.UUID]uint64)
+	var voters = make(map[uuid.UUID]map[uuid.UUID]bool)
+	var choiceQuestions = make(map[uuid.UUID]uuid.UUID)
+	for _, q := range polls {
+		voters[q.ID] = make(map[uuid.UUID]bool)
+		for _, ch := range q.Edges.Choices {
+			choiceQuestions[ch.ID] = q.ID
+		}
+	}
+	for _, a := range answers {
+		if a.Edges.Choice == nil {
+			continue
+		}
+		votes[a.Edges.Choice.ID]++
+		voters[choiceQuestions[a.Edges.Choice.ID]][a.Edges.Answerer.ID] = true
+	}
+
+	var results = make([]PollResult, 0, len(polls))
+	for _, q := range polls {
+		var r = PollResult{Question: q, Votes: make([]uint64, 0, len(q.Edges.Choices)), Voters: uint64(len(voters[q.ID]))}
+		for _, ch := range q.Edges.Choices {
+			r.Votes = append(r.Votes, votes[ch.ID])
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Returns IDs of asked questions, whose answers count towards the results.
+// Only the last attempt of each question (of each player in self-paced sessions) counts: a repeated question is asked anew,
+// so players who do not answer it again get no points for it, and skipping the last attempt voids the question, earlier attempts included.
+// Polls never count.
+// The asked question exclude is considered not to have been asked at all.
+func getScoredAttempts(tx *ent.Tx, sessionId uuid.UUID, exclude uuid.UUID, c context.Context) (map[uuid.UUID]bool, error) {
+	last, err := getLastAttempts(tx, sessionId, exclude, c)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
-	defer tx.Commit()
+	var scored = make(map[uuid.UUID]bool, len(last))
+	for _, aq := range last {
+		if !aq.Skipped && aq.Edges.Question.Type != question.TypePoll {
+			scored[aq.ID] = true
+		}
+	}
+	return scored, nil
+}
 
-	s, err := tx.Session.Query().WithGame().Where(session.HasPlayersWith(player.ID(playerId))).Only(c)
-	if ent.IsNotFound(err) {
-		return nil, nil, nil, NoSuchEntity
-	} else if err != nil {
-		return nil, nil, nil, err
+// Returns the last attempt of each question (of each player in self-paced sessions) with the question loaded.
+// The attempt may have been skipped, which voids the question.
+// The asked question exclude is considered not to have been asked at all.
+func getLastAttempts(tx *ent.Tx, sessionId uuid.UUID, exclude uuid.UUID, c context.Context) ([]*ent.AskedQuestion, error) {
+	attempts, err := tx.AskedQuestion.Query().Where(askedquestion.HasSessionWith(session.ID(sessionId)), askedquestion.IDNEQ(exclude)).WithQuestion().WithPlayer().Order(ent.Asc(askedquestion.FieldAsked)).All(c)
+	if err != nil {
+		return nil, err
+	}
+	type attempt struct {
+		question uuid.UUID
+		player   uuid.UUID // uuid.Nil unless self-paced
+	}
+	var last = make(map[attempt]*ent.AskedQuestion, len(attempts))
+	for _, aq := range attempts {
+		var key = attempt{question: aq.Edges.Question.ID}
+		if aq.Edges.Player != nil {
+			key.player = aq.Edges.Player.ID
+		}
+		last[key] = aq
+	}
+	var lastAttempts = make([]*ent.AskedQuestion, 0, len(last))
+	for _, aq := range last {
+		lastAttempts = append(lastAttempts, aq)
 	}
+	return lastAttempts, nil
+}
 
-	p, err := tx.Player.Query().Where(player.ID(playerId)).Only(c)
-	if ent.IsNotFound(err) {
-		return nil, nil, nil, NoSuchEntity
-	} else if err != nil {
-		return nil, nil, nil, err
+// Returns a query for all non-organiser players of the session with all the edges needed by computeResults.
+// Removed players, whose answers have been kept, are included.
+func queryScoredPlayers(tx *ent.Tx, sessionId uuid.UUID) *ent.PlayerQuery {
+	return tx.Player.Query().Where(player.HasSessionWith(session.ID(sessionId))).Where(player.Organiser(false)).Order(ent.Asc(player.FieldName)).WithTeam().WithAskedQuestions().WithAnswers(func(q *ent.AnswerQuery) {
+		q.WithChoice().WithAskedQuestion(func(q *ent.AskedQuestionQuery) {
+			q.WithQuestion(func(q *ent.QuestionQuery) { q.WithChoices().WithRound() })
+		})
+	})
+}
+
+// Scores and ranks the players, who must have been obtained by queryScoredPlayers.
+// Only answers to the asked questions in scored (see getScoredAttempts) are counted.
+func computeResults(s *ent.Session, players []*ent.Player, scored map[uuid.UUID]bool) []PlayerResult {
+	var closest = closestGuesses(players, scored)
+	var results = make([]PlayerResult, 0, len(players))
+	for _, p := range players {
+		var res PlayerResult
+		res.Player = p
+		res.rounds = make(map[uuid.UUID]float64)
+		var rounds = make(map[uuid.UUID]uuid.UUID) // of asked questions
+		for _, a := range p.Edges.Answers {
+			if r := a.Edges.AskedQuestion.Edges.Question.Edges.Round; r != nil {
+				rounds[a.Edges.AskedQuestion.ID] = r.ID
+			}
+		}
+		for aq, points := range pointsPerQuestion(s, p, scored, closest) {
+			res.points += points
+			if r, ok := rounds[aq]; ok {
+				res.rounds[r] += points
+			}
+		}
+		res.progress = uint64(len(p.Edges.AskedQuestions))
+		results = append(results, res)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].points > results[j].points }) // sort in reverse
+	if len(results) > 0 {
+		results[0].place = 1
+	}
+	var place uint64 = 2
+	for i := 1; i < len(results); i++ {
+		if results[i].Points() == results[i-1].Points() {
+			results[i].place = results[i-1].place
+		} else {
+			results[i].place = place
+		}
+		place++
+	}
+	return results
+}
+
+// Returns the points the player, who must have been obtained by queryScoredPlayers, has gained for each asked question in scored.
+// closest must have been obtained by closestGuesses.
+func pointsPerQuestion(s *ent.Session, p *ent.Player, scored map[uuid.UUID]bool, closest map[uuid.UUID]float64) map[uuid.UUID]float64 {
+	var sets = make(map[uuid.UUID][]*ent.Answer, len(p.Edges.Answers))
+	for _, a := range p.Edges.Answers {
+		if scored[a.Edges.AskedQuestion.ID] {
+			sets[a.Edges.AskedQuestion.ID] = append(sets[a.Edges.AskedQuestion.ID], a)
+		}
+	}
+	var points = make(map[uuid.UUID]float64, len(sets))
+	for aq, set := range sets {
+		points[aq] = setPoints(s, set, closest[set[0].Edges.AskedQuestion.Edges.Question.ID])
+	}
+	return points
+}
+
+// Returns the smallest distance of a guess from the correct value for each number question, considering only the asked questions in scored.
+// players must have been obtained by queryScoredPlayers.
+func closestGuesses(players []*ent.Player, scored map[uuid.UUID]bool) map[uuid.UUID]float64 {
+	var closest = make(map[uuid.UUID]float64)
+	for _, p := range players {
+		for _, a := range p.Edges.Answers {
+			var q = a.Edges.AskedQuestion.Edges.Question
+			if !scored[a.Edges.AskedQuestion.ID] || a.Number == nil || q.Value == nil {
+				continue
+			}
+			var distance = math.Abs(*a.Number - *q.Value)
+			if c, ok := closest[q.ID]; !ok || distance < c {
+				closest[q.ID] = distance
+			}
+		}
 	}
+	return closest
+}
 
-	if players, err := tx.Player.Query().Where(player.HasSessionWith(session.ID(s.ID))).Where(player.Organiser(false)).Order(ent.Asc(player.FieldName)).WithAnswers(func(q *ent.AnswerQuery) { q.WithChoice() }).All(c); err == nil {
-		var results = make([]PlayerResult, 0, len(players))
-		for _, p := range players {
-			var res PlayerResult
-			res.Player = p
-			for _, a := range p.Edges.Answers {
-				if a.Edges.Choice.Correct {
-					res.correct++
+// Returns the points for the set of answers a player has given to a single asked question.
+// A single-choice question scores if the choice is correct.
+// A text answer scores if it matches one of the accepted answers or has been accepted by the organiser.
+// A guess to a number question scores proportionally to its distance from the correct value within the margin of the question,
+// or only if it is among the closest guesses (at the distance closest) if the question has no margin.
+// An ordering scores fully only if all the choices are in the correct order.
+// With partial credit, it scores the share of pairs of choices in the correct order.
+// A multiple-choice set scores fully only if it consists of exactly the correct choices.
+// With partial credit, it scores the correct choices picked less the wrong ones picked as a share of all correct choices, but not less than zero.
+// Partial points are rounded to two decimal places.
+func setPoints(s *ent.Session, set []*ent.Answer, closest float64) float64 {
+	var aq = set[0].Edges.AskedQuestion
+	var value = answerPoints(s.Scoring, aq, set[0])
+	if q := aq.Edges.Question; q.Type == question.TypeNumber {
+		if set[0].Number == nil || q.Value == nil {
+			return 0
+		}
+		var distance = math.Abs(*set[0].Number - *q.Value)
+		if q.Margin > 0 {
+			if share := 1 - distance/q.Margin; share > 0 {
+				return math.Round(value*share*100) / 100
+			}
+		} else if distance == closest {
+			return value
+		}
+		return 0
+	} else if q.Type == question.TypeOrdering {
+		// compare each pair of choices as ordered by the player
+		var ordered = make([]*ent.Answer, len(set))
+		copy(ordered, set)
+		sort.Slice(ordered, func(i, j int) bool { return *ordered[i].Position < *ordered[j].Position })
+		var pairs, correctPairs int
+		for i := range ordered {
+			for j := i + 1; j < len(ordered); j++ {
+				pairs++
+				if ordered[i].Edges.Choice.Order < ordered[j].Edges.Choice.Order {
+					correctPairs++
 				}
 			}
-			results = append(results, res)
 		}
-		sort.SliceStable(results, func(i, j int) bool { return results[i].correct > results[j].correct }) // sort in reverse
-		if len(results) > 0 {
-			results[0].place = 1
+		if correctPairs == pairs {
+			return value
+		} else if s.PartialCredit {
+			return math.Round(value*float64(correctPairs)/float64(pairs)*100) / 100
 		}
-		var place uint64 = 2
-		for i := 1; i < len(results); i++ {
-			if results[i].Points() == results[i-1].Points() {
-				results[i].place = results[i-1].place
-			} else {
-				results[i].place = place
+		return 0
+	} else if aq.Edges.Question.Type == question.TypeText {
+		if textAnswerCorrect(aq.Edges.Question, set[0]) {
+			return value
+		}
+		return 0
+	} else if aq.Edges.Question.Type != question.TypeMultiple {
+		if set[0].Edges.Choice.Correct {
+			return value
+		}
+		return 0
+	}
+
+	var correct, picked, wrong int
+	for _, ch := range aq.Edges.Question.Edges.Choices {
+		if ch.Correct {
+			correct++
+		}
+	}
+	for _, a := range set {
+		if a.Edges.Choice.Correct {
+			picked++
+		} else {
+			wrong++
+		}
+	}
+
+	if picked == correct && wrong == 0 {
+		return value
+	} else if s.PartialCredit && correct > 0 {
+		var share = float64(picked-wrong) / float64(correct)
+		if share > 0 {
+			return math.Round(value*share*100) / 100
+		}
+	}
+	return 0
+}
+
+// Reports whether the text answer a matches one of the accepted answers of the question q, which must have its choices loaded.
+// Answers accepted by the organiser are correct as well.
+func textAnswerCorrect(q *ent.Question, a *ent.Answer) bool {
+	if a.Accepted {
+		return true
+	}
+	for _, ch := range q.Edges.Choices {
+		if textMatch.Matches(a.Text, ch.Title, uint(q.Tolerance)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Combines the scores of the players, who must have been obtained by queryScoredPlayers, into scores of their teams as set by the session.
+// Averages are rounded to two decimal places.
+func computeTeamResults(s *ent.Session, teams []*ent.Team, players []*ent.Player, scored map[uuid.UUID]bool) []TeamResult {
+	var results = make([]TeamResult, len(teams))
+	var index = make(map[uuid.UUID]int, len(teams))
+	for i, t := range teams {
+		results[i].Team = t
+		index[t.ID] = i
+	}
+	for _, p := range players {
+		if p.Edges.Team != nil {
+			var i = index[p.Edges.Team.ID]
+			results[i].Members = append(results[i].Members, p)
+		}
+	}
+
+	var closest = closestGuesses(players, scored)
+	for i := range results {
+		var sum float64
+		var best = make(map[uuid.UUID]float64)
+		for _, p := range results[i].Members {
+			for aq, points := range pointsPerQuestion(s, p, scored, closest) {
+				sum += points
+				if points > best[aq] {
+					best[aq] = points
+				}
 			}
-			place++
 		}
-		return results, s, p, nil
-	} else {
-		return nil, nil, nil, err
+		switch s.TeamScoring {
+		case session.TeamScoringAverage:
+			if len(results[i].Members) > 0 {
+				results[i].points = math.Round(sum/float64(len(results[i].Members))*100) / 100
+			}
+		case session.TeamScoringBest:
+			for _, points := range best {
+				results[i].points += points
+			}
+		default:
+			results[i].points = sum
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].points > results[j].points }) // sort in reverse
+	if len(results) > 0 {
+		results[0].place = 1
+	}
+	var place uint64 = 2
+	for i := 1; i < len(results); i++ {
+		if results[i].Points() == results[i-1].Points() {
+			results[i].place = results[i-1].place
+		} else {
+			results[i].place = place
+		}
+		place++
+	}
+	return results
+}
+
+// Fills in the results of the round ended by the asked question aq of the session s, which must have its question loaded including its round,
+// and the title slide of the round starting with the next question.
+// Rounds end when the next question of the session, see nextQuestion, belongs to another round.
+func fillRounds(tx *ent.Tx, s *ent.Session, aq *ent.AskedQuestion, bu *rtcomm.BreakUpdate, c context.Context) error {
+	var round = aq.Edges.Question.Edges.Round
+	var nextRound *ent.Round
+	if next, err := nextQuestion(tx, s.ID, aq.Edges.Question, c); err == nil {
+		nextRound = next.Edges.Round
+	} else if !errors.Is(err, NoNextQuestion) {
+		return err
+	}
+
+	if round != nil && (nextRound == nil || nextRound.ID != round.ID) {
+		standings, err := getRoundStandings(tx, s, round.ID, c)
+		if err != nil {
+			return err
+		}
+		bu.RoundResults = &rtcomm.RoundResults{Round: getRound(round), Leaderboard: standings}
+	}
+	if nextRound != nil && (round == nil || nextRound.ID != round.ID) {
+		var r = getRound(nextRound)
+		bu.NextRound = &r
+	}
+	return nil
+}
+
+func getRound(r *ent.Round) rtcomm.Round {
+	var ru = rtcomm.Round{
+		Title:     r.Title,
+		TitleHTML: string(markdown.Render(r.Title)),
+	}
+	if r.Intro != "" {
+		ru.IntroHTML = string(markdown.Render(r.Intro))
+	}
+	return ru
+}
+
+// Returns the standings of all players by the points gained in the round of the given ID
+func getRoundStandings(tx *ent.Tx, s *ent.Session, roundId uuid.UUID, c context.Context) ([]rtcomm.Standing, error) {
+	players, err := queryScoredPlayers(tx, s.ID).All(c)
+	if err != nil {
+		return nil, err
+	}
+	scored, err := getScoredAttempts(tx, s.ID, uuid.Nil, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var results = computeResults(s, players, scored)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].RoundPoints(roundId) > results[j].RoundPoints(roundId) }) // sort in reverse
+	var standings = make([]rtcomm.Standing, 0, len(results))
+	for i, r := range results {
+		var place = uint64(i + 1)
+		if i > 0 && r.RoundPoints(roundId) == standings[i-1].Points {
+			place = standings[i-1].Place
+		}
+		standings = append(standings, rtcomm.Standing{
+			Name:   r.Player.Name,
+			Points: r.RoundPoints(roundId),
+			Place:  place,
+		})
+	}
+	return standings, nil
+}
+
+// Returns the standings of all players after the asked question lastAsked
+// along with the change of their place caused by lastAsked.
+func getStandings(tx *ent.Tx, sessionId uuid.UUID, lastAsked uuid.UUID, c context.Context) ([]rtcomm.Standing, error) {
+	s, err := tx.Session.Get(c, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	players, err := queryScoredPlayers(tx, sessionId).All(c)
+	if err != nil {
+		return nil, err
+	}
+
+	previouslyScored, err := getScoredAttempts(tx, sessionId, lastAsked, c)
+	if err != nil {
+		return nil, err
+	}
+	scored, err := getScoredAttempts(tx, sessionId, uuid.Nil, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var previousPlaces = make(map[uuid.UUID]uint64, len(players))
+	for _, r := range computeResults(s, players, previouslyScored) {
+		previousPlaces[r.Player.ID] = r.Place()
 	}
+
+	var current = computeResults(s, players, scored)
+	var standings = make([]rtcomm.Standing, 0, len(current))
+	for _, r := range current {
+		standings = append(standings, rtcomm.Standing{
+			Name:        r.Player.Name,
+			Points:      r.Points(),
+			Place:       r.Place(),
+			PlaceChange: int64(previousPlaces[r.Player.ID]) - int64(r.Place()),
+		})
+	}
+	return standings, nil
+}
+
+const timeWeightedMaxPoints = 1000
+
+// Returns the points for a correct answer a to the asked question aq.
+// With time-weighted scoring, the answer loses up to half of its value linearly over the length of the question including extensions.
+func answerPoints(scoring session.Scoring, aq *ent.AskedQuestion, a *ent.Answer) float64 {
+	switch scoring {
+	case session.ScoringTimeWeighted:
+		var length = time.Duration(aq.Edges.Question.DefaultLength+aq.Extension) * time.Millisecond
+		if length <= 0 {
+			return timeWeightedMaxPoints
+		}
+		var elapsed = a.Answered.Sub(aq.Asked)
+		if elapsed < 0 {
+			elapsed = 0
+		} else if elapsed > length {
+			elapsed = length
+		}
+		return float64(timeWeightedMaxPoints - int64(elapsed)*(timeWeightedMaxPoints/2)/int64(length))
+	default:
+		return 1
+	}
+}
+
+var UnlinkableAnswers = errors.New("some answers cannot be linked to the asked question they answer")
+
+// Reports whether there are answers saved before answers referred to the asked question they answer.
+// Those must be linked by LinkAnswers before results are computed.
+func (m *Model) HasUnlinkedAnswers(c context.Context) (bool, error) {
+	return m.c.Answer.Query().Where(answer.Not(answer.HasAskedQuestion())).Exist(c)
+}
+
+// One-off migration linking answers saved before answers referred to asked questions to the latest question of their choice
+// asked in their player's session before the answer. Returns the number of answers linked.
+// Either all the answers get linked or none does and UnlinkableAnswers is returned, so that no results change silently.
+func (m *Model) LinkAnswers(c context.Context) (int, error) {
+	tx, err := m.c.BeginTx(c, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	answers, err := tx.Answer.Query().Where(answer.Not(answer.HasAskedQuestion())).WithChoice(func(q *ent.ChoiceQuery) {
+		q.WithQuestion()
+	}).WithAnswerer(func(q *ent.PlayerQuery) {
+		q.WithSession()
+	}).All(c)
+	if err != nil {
+		return 0, err
+	}
+	for _, a := range answers {
+		var p, ch = a.Edges.Answerer, a.Edges.Choice
+		if p == nil || p.Edges.Session == nil || ch == nil || ch.Edges.Question == nil {
+			return 0, UnlinkableAnswers
+		}
+		aq, err := tx.AskedQuestion.Query().Where(askedquestion.HasSessionWith(session.ID(p.Edges.Session.ID)), askedquestion.HasQuestionWith(question.ID(ch.Edges.Question.ID)), askedquestion.AskedLTE(a.Answered)).Order(ent.Desc(askedquestion.FieldAsked)).First(c)
+		if ent.IsNotFound(err) {
+			return 0, UnlinkableAnswers
+		} else if err != nil {
+			return 0, err
+		}
+		if err := tx.Answer.UpdateOne(a).SetAskedQuestion(aq).Exec(c); err != nil {
+			return 0, err
+		}
+	}
+	return len(answers), tx.Commit()
+}
+
+// maps question types of the game creator to the stored ones
+var questionTypes = map[gameCreator.QuestionType]question.Type{
+	gameCreator.SingleChoice:   question.TypeSingle,
+	gameCreator.MultipleChoice: question.TypeMultiple,
+	gameCreator.Text:           question.TypeText,
+	gameCreator.Number:         question.TypeNumber,
+	gameCreator.Ordering:       question.TypeOrdering,
+	gameCreator.Poll:           question.TypePoll,
 }
 
 func (m *Model) CreateGame(game gameCreator.Game, name string, author string, c context.Context) (*ent.Game, error) {
@@ -408,40 +2260,245 @@ func (m *Model) CreateGame(game gameCreator.Game, name string, author string, c
 		return nil, err
 	}
 
-	g, err := tx.Game.Create().SetID(uuid.New()).SetCreated(time.Now()).SetName(name).SetAuthor(author).SetCode(string(code)).Save(c)
+	g, err := tx.Game.Create().SetID(uuid.New()).SetCreated(time.Now()).SetName(name).SetAuthor(author).SetCode(string(code)).SetEditToken(uuid.New()).Save(c)
+	if err != nil {
+		return nil, err
+	}
+
+	imageIds, err := createImages(tx, g, game.Images, c)
 	if err != nil {
 		return nil, err
 	}
+	if err := createQuestions(tx, g, game, imageIds, c); err != nil {
+		return nil, err
+	}
+	return g, tx.Commit()
+}
+
+// Stores the images of the game g and returns their IDs keyed by their names
+func createImages(tx *ent.Tx, g *ent.Game, images map[string]gameCreator.Image, c context.Context) (map[string]uuid.UUID, error) {
+	var creates = make([]*ent.ImageCreate, 0, len(images))
+	var imageIds = make(map[string]uuid.UUID, len(images))
+	for name, image := range images {
+		var id = uuid.New()
+		creates = append(creates, tx.Image.Create().SetID(id).SetGame(g).SetName(name).SetType(image.Type).SetData(image.Data))
+		imageIds[name] = id
+	}
+	if _, err := tx.Image.CreateBulk(creates...).Save(c); err != nil {
+		return nil, err
+	}
+	return imageIds, nil
+}
+
+// Stores the rounds, questions and choices of game in the game g, imageIds holds the IDs of its images keyed by their names
+func createQuestions(tx *ent.Tx, g *ent.Game, game gameCreator.Game, imageIds map[string]uuid.UUID, c context.Context) error {
+	var rounds = make([]*ent.RoundCreate, 0, len(game.Rounds))
+	var roundIds = make([]uuid.UUID, 0, len(game.Rounds))
+	for i, r := range game.Rounds {
+		var id = uuid.New()
+		rounds = append(rounds, tx.Round.Create().SetID(id).SetGame(g).SetTitle(r.Title).SetIntro(r.Intro).SetOrder(i+1))
+		roundIds = append(roundIds, id)
+	}
+	if _, err := tx.Round.CreateBulk(rounds...).Save(c); err != nil {
+		return err
+	}
 
 	var questions = make([]*ent.QuestionCreate, 0, len(game.Questions))
 	var questionIds = make([]uuid.UUID, 0, len(game.Questions))
 	var choicesCount uint
 	for i, q := range game.Questions {
 		var id = uuid.New()
-		var questionCreate = tx.Question.Create().SetID(id).SetGame(g).SetDefaultLength(q.Length).SetOrder(i + 1).SetTitle(q.Title)
+		var questionCreate = tx.Question.Create().SetID(id).SetGame(g).SetDefaultLength(q.Length).SetOrder(i + 1).SetTitle(q.Title).SetType(questionTypes[q.Type]).SetTolerance(q.Tolerance).SetCategory(q.Category).SetExplanation(q.Explanation)
+		if q.Type == gameCreator.Number {
+			questionCreate.SetValue(q.Value).SetMargin(q.Margin)
+		} else if q.Type == gameCreator.Poll {
+			questionCreate.SetVotes(q.Votes)
+		}
+		if q.Image != "" {
+			questionCreate.SetImage(imageIds[q.Image])
+		}
+		if q.Round > 0 {
+			questionCreate.SetRoundID(roundIds[q.Round-1])
+		}
 		questions = append(questions, questionCreate)
 		questionIds = append(questionIds, id)
 		choicesCount += uint(len(q.Choices))
 	}
 	if _, err := tx.Question.CreateBulk(questions...).Save(c); err != nil {
-		return nil, err
+		return err
 	}
 
 	var choices = make([]*ent.ChoiceCreate, 0, choicesCount)
 	for i, q := range game.Questions {
-		for _, c := range q.Choices {
-			var choiceCreate = tx.Choice.Create().SetID(uuid.New()).SetTitle(c.Title).SetCorrect(c.Correct).SetQuestionID(questionIds[i])
+		for j, c := range q.Choices {
+			var choiceCreate = tx.Choice.Create().SetID(uuid.New()).SetTitle(c.Title).SetCorrect(c.Correct).SetOrder(j).SetQuestionID(questionIds[i]).SetFeedback(c.Feedback)
+			if c.Image != "" {
+				choiceCreate.SetImage(imageIds[c.Image])
+			}
 			choices = append(choices, choiceCreate)
 		}
 	}
 	if _, err := tx.Choice.CreateBulk(choices...).Save(c); err != nil {
+		return err
+	}
+	return nil
+}
+
+var Outdated = errors.New("there is a newer version of the game")
+
+// Replaces the name, author and questions of the game of the given ID by those of edited.
+// edited must carry the images it refers to, see gameCreator.Game.AttachImages, which must be images of the game.
+// A game that has been played is kept for the sake of results of its sessions, its next version taking over the game's code is created instead.
+// Only the latest version of a game may be updated, see Outdated, and only by those knowing its edit token.
+func (m *Model) UpdateGame(gameId uuid.UUID, editToken uuid.UUID, edited gameCreator.Game, name string, author string, c context.Context) (*ent.Game, error) {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	g, err := tx.Game.Query().Where(game.ID(gameId)).WithImages().WithNext().Only(c)
+	if ent.IsNotFound(err) {
+		return nil, NoSuchEntity
+	} else if err != nil {
+		return nil, err
+	}
+	if !editable(g, editToken) {
+		return nil, Forbidden
+	} else if g.Edges.Next != nil {
+		return nil, Outdated
+	}
+
+	var imageIds map[string]uuid.UUID
+	if played, err := g.QuerySessions().Exist(c); err != nil {
+		return nil, err
+	} else if played {
+		var previous = g
+		if err := tx.Game.UpdateOne(previous).SetCode(previous.Code + "-" + strconv.Itoa(previous.Version)).Exec(c); err != nil {
+			return nil, err
+		}
+		g, err = tx.Game.Create().SetID(uuid.New()).SetCreated(time.Now()).SetName(name).SetAuthor(author).SetCode(previous.Code).SetVersion(previous.Version + 1).SetEditToken(previous.EditToken).SetPrevious(previous).Save(c)
+		if err != nil {
+			return nil, err
+		}
+		if imageIds, err = createImages(tx, g, edited.Images, c); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := tx.Choice.Delete().Where(choice.HasQuestionWith(question.HasGameWith(game.ID(g.ID)))).Exec(c); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Question.Delete().Where(question.HasGameWith(game.ID(g.ID))).Exec(c); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Round.Delete().Where(round.HasGameWith(game.ID(g.ID))).Exec(c); err != nil {
+			return nil, err
+		}
+		imageIds = make(map[string]uuid.UUID, len(g.Edges.Images))
+		for _, image := range g.Edges.Images {
+			imageIds[image.Name] = image.ID
+		}
+		if g, err = tx.Game.UpdateOne(g).SetName(name).SetAuthor(author).Save(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := createQuestions(tx, g, edited, imageIds, c); err != nil {
 		return nil, err
 	}
 	return g, tx.Commit()
 }
 
+// Returns the game of the given ID as read by gameCreator.Parse, including its images, to be edited and saved by UpdateGame
+func (m *Model) ExportGame(gameId uuid.UUID, editToken uuid.UUID, c context.Context) (gameCreator.Game, *ent.Game, error) {
+	g, err := m.c.Game.Query().Where(game.ID(gameId)).WithImages().WithNext().WithRounds(func(q *ent.RoundQuery) {
+		q.Order(ent.Asc(round.FieldOrder))
+	}).WithQuestions(func(q *ent.QuestionQuery) {
+		q.WithChoices(orderChoices).WithRound().Order(ent.Asc(question.FieldOrder))
+	}).Only(c)
+	if ent.IsNotFound(err) {
+		return gameCreator.Game{}, nil, NoSuchEntity
+	} else if err != nil {
+		return gameCreator.Game{}, nil, err
+	}
+	if !editable(g, editToken) {
+		return gameCreator.Game{}, nil, Forbidden
+	}
+
+	var exported = gameCreator.Game{Images: make(map[string]gameCreator.Image, len(g.Edges.Images))}
+	var imageNames = make(map[uuid.UUID]string, len(g.Edges.Images))
+	for _, image := range g.Edges.Images {
+		exported.Images[image.Name] = gameCreator.Image{Type: image.Type, Data: image.Data}
+		imageNames[image.ID] = image.Name
+	}
+	var rounds = make(map[uuid.UUID]int, len(g.Edges.Rounds))
+	for i, r := range g.Edges.Rounds {
+		exported.Rounds = append(exported.Rounds, gameCreator.Round{Title: r.Title, Intro: r.Intro})
+		rounds[r.ID] = i + 1
+	}
+	for _, q := range g.Edges.Questions {
+		var eq = gameCreator.Question{
+			Title:       q.Title,
+			Length:      q.DefaultLength,
+			Tolerance:   q.Tolerance,
+			Margin:      q.Margin,
+			Image:       imageNames[q.Image],
+			Category:    q.Category,
+			Explanation: q.Explanation,
+		}
+		for t, qt := range questionTypes {
+			if qt == q.Type {
+				eq.Type = t
+			}
+		}
+		if q.Value != nil {
+			eq.Value = *q.Value
+		}
+		if q.Type == question.TypePoll {
+			eq.Votes = q.Votes
+		}
+		if q.Edges.Round != nil {
+			eq.Round = rounds[q.Edges.Round.ID]
+		}
+		for _, ch := range q.Edges.Choices {
+			eq.Choices = append(eq.Choices, gameCreator.Choice{
+				Title:    ch.Title,
+				Correct:  ch.Correct,
+				Image:    imageNames[ch.Image],
+				Feedback: ch.Feedback,
+			})
+		}
+		exported.Questions = append(exported.Questions, eq)
+	}
+	return exported, g, nil
+}
+
+// One-off migration issuing edit tokens to games created before edit tokens existed, which cannot be edited otherwise.
+// Returns the games issued a token, so that the secret paths of their editors can be handed over to their authors.
+func (m *Model) IssueEditTokens(c context.Context) ([]*ent.Game, error) {
+	tx, err := m.c.BeginTx(c, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	games, err := tx.Game.Query().Where(game.Or(game.EditTokenIsNil(), game.EditToken(uuid.Nil))).Order(ent.Asc(game.FieldCreated)).All(c)
+	if err != nil {
+		return nil, err
+	}
+	for i, g := range games {
+		if games[i], err = g.Update().SetEditToken(uuid.New()).Save(c); err != nil {
+			return nil, err
+		}
+	}
+	return games, tx.Commit()
+}
+
 func (m *Model) GetGameWithQuestionsAndChoices(gameId uuid.UUID, c context.Context) (*ent.Game, error) {
-	if game, err := m.c.Game.Query().Where(game.ID(gameId)).WithQuestions(func(q *ent.QuestionQuery) { q.WithChoices() }).Only(c); err == nil {
+	if game, err := m.c.Game.Query().Where(game.ID(gameId)).WithPrevious().WithNext().WithQuestions(func(q *ent.QuestionQuery) {
+		q.WithChoices(orderChoices).WithRound().Order(ent.Asc(question.FieldOrder))
+	}).Only(c); err == nil {
 		return game, err
 	} else if ent.IsNotFound(err) {
 		return nil, NoSuchEntity
@@ -450,6 +2507,16 @@ func (m *Model) GetGameWithQuestionsAndChoices(gameId uuid.UUID, c context.Conte
 	}
 }
 
+func (m *Model) GetImage(imageId uuid.UUID, c context.Context) (*ent.Image, error) {
+	if image, err := m.c.Image.Get(c, imageId); err == nil {
+		return image, nil
+	} else if ent.IsNotFound(err) {
+		return nil, NoSuchEntity
+	} else {
+		return nil, err
+	}
+}
+
 const codeRandomPartLength uint8 = 3
 
 func (m *Model) getCodeIncremental(c context.Context) (uint64, error) {
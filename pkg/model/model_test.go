@@ -2,33 +2,46 @@ package model
 
 import (
 	"context"
+	entsql "entgo.io/ent/dialect/sql"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
+	"math/rand"
 	"net/url"
+	"reflect"
+	"sort"
 	"testing"
 	"time"
+	"vkane.cz/tinyquiz/pkg/gameCreator"
 	"vkane.cz/tinyquiz/pkg/model/ent"
+	"vkane.cz/tinyquiz/pkg/model/ent/answer"
+	"vkane.cz/tinyquiz/pkg/model/ent/askedquestion"
+	"vkane.cz/tinyquiz/pkg/model/ent/choice"
+	"vkane.cz/tinyquiz/pkg/model/ent/game"
+	"vkane.cz/tinyquiz/pkg/model/ent/question"
+	"vkane.cz/tinyquiz/pkg/model/ent/session"
+	"vkane.cz/tinyquiz/pkg/rtcomm"
 )
 
-func newTestDb(t *testing.T) *ent.Client {
-	c, err := ent.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=private&_fk=1", url.PathEscape(t.Name())))
-	ent.NewClient()
+func newTestDb(t *testing.T) (*ent.Client, *entsql.Driver) {
+	drv, err := entsql.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=private&_fk=1", url.PathEscape(t.Name())))
 	if err != nil {
 		t.Fatalf("Could not create temporary database: %v", err)
 	}
+	c := ent.NewClient(ent.Driver(drv))
 	if err = c.Schema.Create(context.Background()); err != nil {
 		t.Fatalf("Could not initialize schema in temporary database: %v", err)
 	}
 	t.Cleanup(func() {
 		c.Close()
 	})
-	return c
+	return c, drv
 }
 
 func newTestModel(t *testing.T) *Model {
-	return NewModel(newTestDb(t))
+	c, _ := newTestDb(t)
+	return NewModel(c)
 }
 
 func newTestModelWithData(t *testing.T) *Model {
@@ -92,8 +105,8 @@ func newTestModelWithData(t *testing.T) *Model {
 	askedQuestions := tx.AskedQuestion.CreateBulk(askedQuestionsC...).SaveX(c)
 
 	var answersC = []*ent.AnswerCreate{
-		tx.Answer.Create().SetID(uuid.MustParse("387e626f-aed1-4bb3-953f-744763018178")).SetAnswered(time.Unix(1613387999, 0)).SetChoice(choices[0]).SetAnswerer(players[2]),
-		tx.Answer.Create().SetID(uuid.MustParse("e26a530e-48ce-4268-8f84-cfe661e2a32a")).SetAnswered(time.Unix(1613388000, 0)).SetChoice(choices[2]).SetAnswerer(players[4]),
+		tx.Answer.Create().SetID(uuid.MustParse("387e626f-aed1-4bb3-953f-744763018178")).SetAnswered(time.Unix(1613387999, 0)).SetChoice(choices[0]).SetAnswerer(players[2]).SetAskedQuestion(askedQuestions[0]),
+		tx.Answer.Create().SetID(uuid.MustParse("e26a530e-48ce-4268-8f84-cfe661e2a32a")).SetAnswered(time.Unix(1613388000, 0)).SetChoice(choices[2]).SetAnswerer(players[4]).SetAskedQuestion(askedQuestions[0]),
 	}
 
 	answers := tx.Answer.CreateBulk(answersC...).SaveX(c)
@@ -112,7 +125,7 @@ func TestModel_NextQuestion(t *testing.T) {
 	m := newTestModelWithData(t)
 	c := context.Background()
 
-	if err := m.NextQuestion(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613388006, 0), c); err != nil {
+	if err := m.NextQuestion(uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1"), time.Unix(1613388006, 0), c); err != nil {
 		t.Fatalf("Unexpected error when switching to next question: %v", err)
 	}
 }
@@ -122,25 +135,36 @@ func TestModel_NextQuestion_noNextQuestion(t *testing.T) {
 	m := newTestModelWithData(t)
 	c := context.Background()
 
-	if err := m.NextQuestion(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613388005, 0), c); err != nil {
+	if err := m.NextQuestion(uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1"), time.Unix(1613388005, 0), c); err != nil {
 		t.Fatalf("Unexpected error when switching to next question (closing the current one): %v", err)
 	}
 
-	if err := m.NextQuestion(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613388006, 0), c); err != nil {
+	if err := m.NextQuestion(uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1"), time.Unix(1613388006, 0), c); err != nil {
 		t.Fatalf("Unexpected error when switching to next question (from a break): %v", err)
 	}
 
-	if err := m.NextQuestion(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613388007, 0), c); err != nil {
+	if err := m.NextQuestion(uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1"), time.Unix(1613388007, 0), c); err != nil {
 		t.Fatalf("Unexpected error when switching to next question (from a break): %v", err)
 	}
 
-	if err := m.NextQuestion(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613388008, 0), c); err == nil {
+	if err := m.NextQuestion(uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1"), time.Unix(1613388008, 0), c); err == nil {
 		t.Fatalf("Switching to next question from the last one did not fail")
 	} else if !errors.Is(err, NoNextQuestion) {
 		t.Fatalf("Unexpected error type after switching to next question from the last one: %v", err)
 	}
 }
 
+func TestModel_NextQuestion_forbidden(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+
+	if err := m.NextQuestion(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf"), time.Unix(1613388006, 0), c); err == nil {
+		t.Fatalf("A plain player was allowed to switch to next question")
+	} else if !errors.Is(err, Forbidden) {
+		t.Fatalf("Unexpected error type after a plain player tried to switch to next question: %v", err)
+	}
+}
+
 func TestModel_SaveAnswer(t *testing.T) {
 	m := newTestModelWithData(t)
 	c := context.Background()
@@ -151,6 +175,16 @@ func TestModel_SaveAnswer(t *testing.T) {
 	}
 }
 
+func TestModel_SaveAnswers_single(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	choiceIds := []uuid.UUID{uuid.MustParse("5155b997-eb2c-4cd0-a067-2bb01379730f"), uuid.MustParse("b88b7f4e-1b17-49ea-8e90-cf42ae4e0f09")}
+
+	if _, err := m.SaveAnswers(uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0"), choiceIds, time.Unix(1613388000, 0), c); !errors.Is(err, InvalidAnswer) {
+		t.Fatalf("Expected several answers to a single-choice question to be refused, got: %v", err)
+	}
+}
+
 func TestModel_SaveAnswer_again(t *testing.T) {
 	m := newTestModelWithData(t)
 	c := context.Background()
@@ -190,7 +224,7 @@ func TestModel_SaveAnswer_closed(t *testing.T) {
 	m := newTestModelWithData(t)
 	c := context.Background()
 
-	if err := m.NextQuestion(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613387997, 0), c); err != nil {
+	if err := m.NextQuestion(uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1"), time.Unix(1613387997, 0), c); err != nil {
 		t.Fatalf("Unexpected error when switching to next question (closing the current one): %v", err)
 	}
 
@@ -200,3 +234,1394 @@ func TestModel_SaveAnswer_closed(t *testing.T) {
 		t.Fatalf("Saving answer to closed question failed with unexpected error type: %v", err)
 	}
 }
+
+func TestModel_GetResults(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+
+	results, _, _, _, err := m.GetResults(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf"), c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	var expected = map[string]float64{"Bob": 1, "Lisa ❤️": 0, "Petr": 0}
+	if len(results) != len(expected) {
+		t.Fatalf("Unexpected number of results: %d", len(results))
+	}
+	for _, r := range results {
+		if points, ok := expected[r.Player.Name]; !ok || points != r.Points() {
+			t.Errorf("Player %s has %v points, expected %v", r.Player.Name, r.Points(), points)
+		}
+	}
+	if results[0].Player.Name != "Bob" || results[0].Place() != 1 {
+		t.Errorf("Bob is expected to be the first, got %s on place %d", results[0].Player.Name, results[0].Place())
+	}
+}
+
+func TestModel_LinkAnswers(t *testing.T) {
+	db, drv := newTestDb(t)
+	m := NewModel(db)
+	c := context.Background()
+
+	g := m.c.Game.Create().SetID(uuid.New()).SetName("Chemistry").SetCode("chem").SetCreated(time.Unix(1613390000, 0)).SetAuthor("me").SaveX(c)
+	q := m.c.Question.Create().SetID(uuid.New()).SetTitle("H2O is").SetOrder(1).SetDefaultLength(10000).SetGame(g).SaveX(c)
+	water := m.c.Choice.Create().SetID(uuid.New()).SetTitle("Water").SetCorrect(true).SetQuestion(q).SaveX(c)
+	s := m.c.Session.Create().SetID(uuid.New()).SetCreated(time.Unix(1613390000, 0)).SetCode("chem1").SetGame(g).SaveX(c)
+	organiser := m.c.Player.Create().SetID(uuid.New()).SetName("teacher").SetJoined(time.Unix(1613390000, 0)).SetOrganiser(true).SetSession(s).SaveX(c)
+	alice := m.c.Player.Create().SetID(uuid.New()).SetName("alice").SetJoined(time.Unix(1613390001, 0)).SetSession(s).SaveX(c)
+	aq := m.c.AskedQuestion.Create().SetID(uuid.New()).SetAsked(time.Unix(1613390010, 0)).SetQuestion(q).SetSession(s).SaveX(c)
+	m.c.Answer.Create().SetID(uuid.New()).SetAnswered(time.Unix(1613390012, 0)).SetChoice(water).SetAnswerer(alice).SetAskedQuestion(aq).SaveX(c)
+
+	// older versions did not link answers to asked questions
+	unlink := func() {
+		if _, err := drv.DB().ExecContext(c, "UPDATE answers SET asked_question_answers = NULL"); err != nil {
+			t.Fatalf("Unlinking answers failed: %v", err)
+		}
+	}
+	unlink()
+	if unlinked, err := m.HasUnlinkedAnswers(c); err != nil || !unlinked {
+		t.Fatalf("Expected the answer to be reported as unlinked, got %v, %v", unlinked, err)
+	}
+	if linked, err := m.LinkAnswers(c); err != nil || linked != 1 {
+		t.Fatalf("Expected the answer to be linked, got %d, %v", linked, err)
+	}
+	if unlinked, err := m.HasUnlinkedAnswers(c); err != nil || unlinked {
+		t.Fatalf("Expected no unlinked answers after linking, got %v, %v", unlinked, err)
+	}
+	if results, _, _, _, err := m.GetResults(organiser.ID, c); err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	} else if len(results) != 1 || results[0].Points() != 1 {
+		t.Errorf("Expected the linked answer to be scored, got %#v", results)
+	}
+
+	// an answer older than any asked question fails the whole migration
+	bob := m.c.Player.Create().SetID(uuid.New()).SetName("bob").SetJoined(time.Unix(1613390001, 0)).SetSession(s).SaveX(c)
+	m.c.Answer.Create().SetID(uuid.New()).SetAnswered(time.Unix(1613390005, 0)).SetChoice(water).SetAnswerer(bob).SetAskedQuestion(aq).SaveX(c)
+	unlink()
+	if _, err := m.LinkAnswers(c); !errors.Is(err, UnlinkableAnswers) {
+		t.Fatalf("Expected linking to fail, got: %v", err)
+	}
+	if count := m.c.Answer.Query().Where(answer.HasAskedQuestion()).CountX(c); count != 0 {
+		t.Errorf("Expected no answer to be linked after a failure, got %d", count)
+	}
+}
+
+func TestModel_GetResults_timeWeighted(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	m.c.Session.Update().SetScoring(session.ScoringTimeWeighted).ExecX(c)
+
+	// Bob answered correctly 3 seconds into a 30 second long question
+	results, _, _, _, err := m.GetResults(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf"), c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	if results[0].Player.Name != "Bob" || results[0].Points() != 950 {
+		t.Errorf("Expected Bob to have 950 points, got %s with %v points", results[0].Player.Name, results[0].Points())
+	}
+}
+
+func TestModel_GetQuestionStateUpdate_break(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+
+	if err := m.NextQuestion(organiserId, time.Unix(1613388005, 0), c); err != nil {
+		t.Fatalf("Unexpected error when switching to next question (closing the current one): %v", err)
+	}
+
+	su, err := m.GetQuestionStateUpdate(sessionId, time.Unix(1613388006, 0), c)
+	if err != nil {
+		t.Fatalf("Getting question state update failed: %v", err)
+	} else if su.Break == nil {
+		t.Fatalf("Expected a break, got %#v", su)
+	}
+	var expected = []rtcomm.Standing{
+		{Name: "Bob", Points: 1, Place: 1, PlaceChange: 0},
+		{Name: "Lisa ❤️", Points: 0, Place: 2, PlaceChange: -1},
+		{Name: "Petr", Points: 0, Place: 2, PlaceChange: -1},
+	}
+	if !reflect.DeepEqual(su.Break.Leaderboard, expected) {
+		t.Fatalf("Unexpected leaderboard:\n\tActual: %#v\n\tExpected: %#v", su.Break.Leaderboard, expected)
+	}
+
+	var expectedCounts = map[string]uint64{
+		"7be00601-d316-46ef-842d-d7b25235905f": 1,
+		"9bd328e9-7a6f-4c39-9d91-7302a5916eeb": 0,
+		"b88b7f4e-1b17-49ea-8e90-cf42ae4e0f09": 1,
+		"5155b997-eb2c-4cd0-a067-2bb01379730f": 0,
+	}
+	if len(su.Break.Answers) != len(expectedCounts) {
+		t.Fatalf("Unexpected number of answers in break: %d", len(su.Break.Answers))
+	}
+	for _, a := range su.Break.Answers {
+		if count, ok := expectedCounts[a.ID]; !ok || count != a.Count {
+			t.Errorf("Choice %s (%s) has been picked %d times, expected %d", a.ID, a.Title, a.Count, count)
+		}
+		if a.Correct != (a.ID == "7be00601-d316-46ef-842d-d7b25235905f") {
+			t.Errorf("Choice %s (%s) has wrong correctness", a.ID, a.Title)
+		}
+	}
+	if picked := su.Break.Picks["Petr"]; !reflect.DeepEqual(picked, []string{"b88b7f4e-1b17-49ea-8e90-cf42ae4e0f09"}) {
+		t.Errorf("Unexpected choices picked by Petr: %v", picked)
+	}
+}
+
+func TestModel_GetProgressStateUpdate(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+
+	su, err := m.GetProgressStateUpdate(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), c)
+	if err != nil {
+		t.Fatalf("Getting progress state update failed: %v", err)
+	} else if su.Progress == nil {
+		t.Fatalf("Expected progress of the open question, got %#v", su)
+	}
+	var expected = rtcomm.ProgressUpdate{Answered: 2, Players: 3, Missing: []string{"Lisa ❤️"}}
+	if !reflect.DeepEqual(*su.Progress, expected) {
+		t.Fatalf("Unexpected progress:\n\tActual: %#v\n\tExpected: %#v", *su.Progress, expected)
+	}
+}
+
+func TestModel_AutoAdvance(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+
+	if advanced, next, err := m.AutoAdvance(sessionId, time.Unix(1613388000, 0), c); err != nil {
+		t.Fatalf("Advancing automatically failed: %v", err)
+	} else if advanced || !next.IsZero() {
+		t.Fatalf("Session without automation has been advanced (%v) or scheduled (%v)", advanced, next)
+	}
+
+	m.c.Session.UpdateOneID(sessionId).SetAutoAdvance(true).ExecX(c)
+	if advanced, next, err := m.AutoAdvance(sessionId, time.Unix(1613388000, 0), c); err != nil {
+		t.Fatalf("Advancing automatically failed: %v", err)
+	} else if advanced {
+		t.Fatalf("Question has been closed before everyone answered")
+	} else if !next.Equal(time.Unix(1613388026, 0)) {
+		t.Fatalf("Unexpected time of the next automatic advance: %v", next)
+	}
+
+	if _, err := m.SaveAnswer(uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0"), uuid.MustParse("5155b997-eb2c-4cd0-a067-2bb01379730f"), time.Unix(1613388001, 0), c); err != nil {
+		t.Fatalf("Saving answer failed: %v", err)
+	}
+	if advanced, next, err := m.AutoAdvance(sessionId, time.Unix(1613388001, 0), c); err != nil {
+		t.Fatalf("Advancing automatically failed: %v", err)
+	} else if !advanced || !next.IsZero() {
+		t.Fatalf("Question has not been closed after everyone answered (%v, %v)", advanced, next)
+	}
+}
+
+func TestModel_AutoAdvance_deadline(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	m.c.Session.UpdateOneID(sessionId).SetAutoAdvance(true).ExecX(c)
+
+	if advanced, _, err := m.AutoAdvance(sessionId, time.Unix(1613388026, 0), c); err != nil {
+		t.Fatalf("Advancing automatically failed: %v", err)
+	} else if !advanced {
+		t.Fatalf("Question has not been closed after its deadline")
+	}
+	if su, err := m.GetQuestionStateUpdate(sessionId, time.Unix(1613388027, 0), c); err != nil {
+		t.Fatalf("Getting question state update failed: %v", err)
+	} else if su.Break == nil {
+		t.Fatalf("Expected a break after closing the question automatically, got %#v", su)
+	}
+}
+
+func TestModel_AutoAdvance_autopilot(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	m.c.Session.UpdateOneID(sessionId).SetAutopilot(true).SetBreakLength(5000).ExecX(c)
+
+	var steps = []struct {
+		now      int64
+		advanced bool
+		next     int64
+	}{
+		{1613388000, false, 1613388026}, // the first question is still open
+		{1613388026, true, 1613388031},  // closed, followed by a break
+		{1613388030, false, 1613388031}, // still in the break
+		{1613388031, true, 1613388061},  // the second question
+		{1613388061, true, 1613388066},  // closed, followed by a break
+		{1613388066, true, 0},           // finished
+	}
+	for _, s := range steps {
+		advanced, next, err := m.AutoAdvance(sessionId, time.Unix(s.now, 0), c)
+		if err != nil {
+			t.Fatalf("Advancing automatically at %d failed: %v", s.now, err)
+		}
+		var expectedNext time.Time
+		if s.next != 0 {
+			expectedNext = time.Unix(s.next, 0)
+		}
+		if advanced != s.advanced || !next.Equal(expectedNext) {
+			t.Fatalf("Advancing automatically at %d returned (%v, %v), expected (%v, %v)", s.now, advanced, next, s.advanced, expectedNext)
+		}
+	}
+
+	if su, err := m.GetQuestionStateUpdate(sessionId, time.Unix(1613388067, 0), c); err != nil {
+		t.Fatalf("Getting question state update failed: %v", err)
+	} else if !su.Results {
+		t.Fatalf("Expected results after the session has finished, got %#v", su)
+	}
+}
+
+func TestModel_AutoAdvance_autopilotStart(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	s, _, err := m.CreateSession("kiosk", "abcdef", SessionOptions{Autopilot: true, BreakLength: 5 * time.Second, MinPlayers: 2}, time.Unix(1613390000, 0), c)
+	if err != nil {
+		t.Fatalf("Creating session failed: %v", err)
+	}
+
+	var steps = []struct {
+		join     string // the player joining before advancing
+		now      int64
+		advanced bool
+		next     int64
+	}{
+		{"", 1613390001, false, 0},               // nobody has joined yet
+		{"alice", 1613390011, false, 0},          // a single player is not enough
+		{"bob", 1613390031, false, 1613390035},   // a break after the second player has joined
+		{"", 1613390035, true, 1613390065},       // the first question
+		{"carol", 1613390036, false, 1613390065}, // later players do not delay the question
+	}
+	for _, step := range steps {
+		if step.join != "" {
+			if _, err := m.RegisterPlayer(step.join, "", s.Code, time.Unix(step.now-1, 0), c); err != nil {
+				t.Fatalf("Registering player failed: %v", err)
+			}
+		}
+		advanced, next, err := m.AutoAdvance(s.ID, time.Unix(step.now, 0), c)
+		if err != nil {
+			t.Fatalf("Advancing automatically at %d failed: %v", step.now, err)
+		}
+		var expectedNext time.Time
+		if step.next != 0 {
+			expectedNext = time.Unix(step.next, 0)
+		}
+		if advanced != step.advanced || !next.Equal(expectedNext) {
+			t.Fatalf("Advancing automatically at %d returned (%v, %v), expected (%v, %v)", step.now, advanced, next, step.advanced, expectedNext)
+		}
+	}
+}
+
+func TestModel_PauseQuestion(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+
+	// the question has been asked at 1613387996 and lasts 30 s
+	if err := m.PauseQuestion(organiserId, time.Unix(1613388016, 0), c); err != nil {
+		t.Fatalf("Pausing question failed: %v", err)
+	}
+	if su, err := m.GetTimerStateUpdate(sessionId, time.Unix(1613388100, 0), c); err != nil {
+		t.Fatalf("Getting timer state update failed: %v", err)
+	} else if su.Timer == nil || !su.Timer.Paused || su.Timer.RemainingTime != 10000 {
+		t.Fatalf("Unexpected timer of a paused question: %#v", su.Timer)
+	}
+	if _, err := m.SaveAnswer(uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0"), uuid.MustParse("5155b997-eb2c-4cd0-a067-2bb01379730f"), time.Unix(1613388100, 0), c); err == nil {
+		t.Fatalf("Saving answer to a paused question succeeded")
+	} else if !errors.Is(err, QuestionPaused) {
+		t.Fatalf("Saving answer to a paused question failed with unexpected error type: %v", err)
+	}
+
+	if err := m.ResumeQuestion(organiserId, time.Unix(1613388100, 0), c); err != nil {
+		t.Fatalf("Resuming question failed: %v", err)
+	}
+	if _, err := m.SaveAnswer(uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0"), uuid.MustParse("5155b997-eb2c-4cd0-a067-2bb01379730f"), time.Unix(1613388100, 0), c); err != nil {
+		t.Fatalf("Saving answer to a resumed question failed: %v", err)
+	}
+	if err := m.ExtendQuestion(organiserId, 15*time.Second, time.Unix(1613388100, 0), c); err != nil {
+		t.Fatalf("Extending question failed: %v", err)
+	}
+	if su, err := m.GetTimerStateUpdate(sessionId, time.Unix(1613388101, 0), c); err != nil {
+		t.Fatalf("Getting timer state update failed: %v", err)
+	} else if su.Timer == nil || su.Timer.Paused || su.Timer.RemainingTime != 24000 {
+		t.Fatalf("Unexpected timer of a resumed and extended question: %#v", su.Timer)
+	}
+}
+
+func TestModel_ExtendQuestion_closed(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+
+	if err := m.ExtendQuestion(organiserId, 15*time.Second, time.Unix(1613388027, 0), c); err == nil {
+		t.Fatalf("Extending a question after its deadline succeeded")
+	} else if !errors.Is(err, QuestionClosed) {
+		t.Fatalf("Extending a question after its deadline failed with unexpected error type: %v", err)
+	}
+}
+
+func TestModel_SkipQuestion(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+
+	// voids the first question, which Bob answered correctly
+	if err := m.SkipQuestion(organiserId, time.Unix(1613388005, 0), c); err != nil {
+		t.Fatalf("Skipping question failed: %v", err)
+	}
+	if su, err := m.GetQuestionStateUpdate(sessionId, time.Unix(1613388006, 0), c); err != nil {
+		t.Fatalf("Getting question state update failed: %v", err)
+	} else if su.Question == nil || su.Question.Title != "What is the capital of the USA?" {
+		t.Fatalf("Expected the second question to be asked after skipping, got %#v", su)
+	}
+
+	results, _, _, _, err := m.GetResults(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf"), c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Points() != 0 {
+			t.Errorf("Player %s has %v points for a skipped question", r.Player.Name, r.Points())
+		}
+	}
+}
+
+func TestModel_RepeatQuestion(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+	bob := uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf")
+
+	if err := m.RepeatQuestion(organiserId, time.Unix(1613388005, 0), c); err != nil {
+		t.Fatalf("Repeating question failed: %v", err)
+	}
+	// Bob answers wrong this time
+	if _, err := m.SaveAnswer(bob, uuid.MustParse("9bd328e9-7a6f-4c39-9d91-7302a5916eeb"), time.Unix(1613388006, 0), c); err != nil {
+		t.Fatalf("Answering repeated question failed: %v", err)
+	}
+
+	results, _, _, _, err := m.GetResults(bob, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Points() != 0 {
+			t.Errorf("Player %s has %v points, while only the last attempt shall count", r.Player.Name, r.Points())
+		}
+	}
+}
+
+func TestModel_RepeatQuestion_skipped(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+	bob := uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf")
+
+	// Bob's correct answer to the first attempt does not count again once the repeated question is skipped
+	if err := m.RepeatQuestion(organiserId, time.Unix(1613388005, 0), c); err != nil {
+		t.Fatalf("Repeating question failed: %v", err)
+	}
+	if err := m.SkipQuestion(organiserId, time.Unix(1613388006, 0), c); err != nil {
+		t.Fatalf("Skipping question failed: %v", err)
+	}
+
+	results, _, _, _, err := m.GetResults(bob, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Points() != 0 {
+			t.Errorf("Player %s has %v points for a question, whose last attempt has been skipped", r.Player.Name, r.Points())
+		}
+	}
+}
+
+func TestModel_AskQuestion(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+
+	if err := m.AskQuestion(organiserId, uuid.MustParse("adb9b601-9ae7-4d91-8998-968d9848eeb4"), time.Unix(1613388005, 0), c); err != nil {
+		t.Fatalf("Jumping to a question failed: %v", err)
+	}
+	if su, err := m.GetQuestionStateUpdate(sessionId, time.Unix(1613388006, 0), c); err != nil {
+		t.Fatalf("Getting question state update failed: %v", err)
+	} else if su.Question == nil || su.Question.Title != "What is the capital of the USA?" {
+		t.Fatalf("Expected the second question to be asked, got %#v", su)
+	}
+
+	if err := m.AskQuestion(organiserId, uuid.MustParse("7be00601-d316-46ef-842d-d7b25235905f"), time.Unix(1613388007, 0), c); err == nil {
+		t.Fatalf("Jumping to a non-existent question succeeded")
+	} else if !errors.Is(err, NoSuchEntity) {
+		t.Fatalf("Jumping to a non-existent question failed with unexpected error type: %v", err)
+	}
+}
+
+func TestModel_KickPlayer_keepAnswers(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+	bob := uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf")
+
+	if _, err := m.KickPlayer(organiserId, "Bob", true, false, time.Unix(1613388000, 0), c); err != nil {
+		t.Fatalf("Kicking a player failed: %v", err)
+	}
+
+	if su, err := m.GetPlayersStateUpdate(sessionId, c); err != nil {
+		t.Fatalf("Getting players state update failed: %v", err)
+	} else {
+		for _, p := range su.Players {
+			if p.Name == "Bob" {
+				t.Fatalf("Kicked player is still listed among players")
+			}
+		}
+	}
+
+	if _, err := m.SaveAnswer(bob, uuid.MustParse("7be00601-d316-46ef-842d-d7b25235905f"), time.Unix(1613388001, 0), c); !errors.Is(err, Kicked) {
+		t.Fatalf("Expected answer of a kicked player to be refused, got: %v", err)
+	}
+
+	results, _, _, _, err := m.GetResults(organiserId, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	} else if len(results) != 3 || results[0].Player.Name != "Bob (1)" || results[0].Points() != 1 {
+		t.Fatalf("Expected the kicked player to keep their points, got %#v", results)
+	}
+}
+
+func TestModel_KickPlayer_rejoin(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+
+	for i, keepAnswers := range []bool{true, true, false} {
+		if _, err := m.KickPlayer(organiserId, "Bob", keepAnswers, false, time.Unix(1613388000, 0), c); err != nil {
+			t.Fatalf("Kicking a player (%d) failed: %v", i, err)
+		}
+		if _, err := m.RegisterPlayer("Bob", "", "abcdef", time.Unix(1613388001, 0), c); err != nil {
+			t.Fatalf("Rejoining under the name of a kicked player (%d) failed: %v", i, err)
+		}
+	}
+
+	results, _, _, _, err := m.GetResults(organiserId, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	// the first Bob keeps his point, the second one has none and the third one has been dropped
+	var expected = map[string]float64{"Bob": 0, "Bob (1)": 1, "Bob (2)": 0, "Lisa ❤️": 0, "Petr": 0}
+	if len(results) != len(expected) {
+		t.Fatalf("Unexpected number of results: %d", len(results))
+	}
+	for _, r := range results {
+		if points, ok := expected[r.Player.Name]; !ok || points != r.Points() {
+			t.Errorf("Player %s has %v points, expected %v", r.Player.Name, r.Points(), points)
+		}
+	}
+}
+
+func TestModel_KickPlayer_ban(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+
+	if _, err := m.KickPlayer(organiserId, "Bob", false, true, time.Unix(1613388000, 0), c); err != nil {
+		t.Fatalf("Kicking a player failed: %v", err)
+	}
+
+	results, _, _, _, err := m.GetResults(organiserId, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Player.Name == "Bob" {
+			t.Fatalf("Kicked player is still listed in the results")
+		}
+	}
+
+	if _, err := m.RegisterPlayer("bob", "", "abcdef", time.Unix(1613388001, 0), c); !errors.Is(err, Banned) {
+		t.Fatalf("Expected a banned name to be refused, got: %v", err)
+	}
+
+	if _, err := m.KickPlayer(organiserId, "M. Black", false, false, time.Unix(1613388002, 0), c); !errors.Is(err, Forbidden) {
+		t.Fatalf("Expected kicking an organiser to be forbidden, got: %v", err)
+	}
+}
+
+func newTestModelWithTeams(t *testing.T) *Model {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	sessionId := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+
+	red := m.c.Team.Create().SetID(uuid.MustParse("0d1c8b6e-4bb0-4f7e-9a57-3f6e2b1d5a01")).SetName("Red").SetColour("#e53935").SetOrder(0).SetSessionID(sessionId).SaveX(c)
+	blue := m.c.Team.Create().SetID(uuid.MustParse("6a0f3e55-2d8c-4c1e-8b0a-9e4f7c2d1b02")).SetName("Blue").SetColour("#1e88e5").SetOrder(1).SetSessionID(sessionId).SaveX(c)
+	m.c.Player.UpdateOneID(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf")).SetTeam(red).ExecX(c)  // Bob
+	m.c.Player.UpdateOneID(uuid.MustParse("cd0afe61-2c89-473f-9269-bbcb50016941")).SetTeam(red).ExecX(c)  // Petr
+	m.c.Player.UpdateOneID(uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0")).SetTeam(blue).ExecX(c) // Lisa
+	return m
+}
+
+func TestModel_RegisterPlayer_team(t *testing.T) {
+	m := newTestModelWithTeams(t)
+	c := context.Background()
+
+	if p, err := m.RegisterPlayer("eve", "", "abcdef", time.Unix(1613388000, 0), c); err != nil {
+		t.Fatalf("Registering a player failed: %v", err)
+	} else if team := p.Unwrap().QueryTeam().OnlyX(c); team.Name != "Blue" {
+		t.Fatalf("Expected the player to join the smallest team, got %s", team.Name)
+	}
+
+	if p, err := m.RegisterPlayer("joe", "red", "abcdef", time.Unix(1613388001, 0), c); err != nil {
+		t.Fatalf("Registering a player failed: %v", err)
+	} else if team := p.Unwrap().QueryTeam().OnlyX(c); team.Name != "Red" {
+		t.Fatalf("Expected the player to join the picked team, got %s", team.Name)
+	}
+
+	if _, err := m.RegisterPlayer("ann", "green", "abcdef", time.Unix(1613388002, 0), c); !errors.Is(err, NoSuchTeam) {
+		t.Fatalf("Expected joining a non-existent team to fail, got: %v", err)
+	}
+}
+
+func TestModel_GetResults_teams(t *testing.T) {
+	var expected = map[session.TeamScoring]float64{
+		session.TeamScoringSum:     3,
+		session.TeamScoringAverage: 1.5,
+		session.TeamScoringBest:    2,
+	}
+	for scoring, points := range expected {
+		m := newTestModelWithTeams(t)
+		c := context.Background()
+		organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+		m.c.Session.UpdateOneID(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")).SetTeamScoring(scoring).ExecX(c)
+
+		// both Bob and Petr answer the second question correctly
+		if err := m.NextQuestion(organiserId, time.Unix(1613388005, 0), c); err != nil {
+			t.Fatalf("Closing the first question failed: %v", err)
+		}
+		if err := m.NextQuestion(organiserId, time.Unix(1613388006, 0), c); err != nil {
+			t.Fatalf("Asking the second question failed: %v", err)
+		}
+		for _, p := range []string{"f8cd85a4-8b46-4145-abaf-df924a7719cf", "cd0afe61-2c89-473f-9269-bbcb50016941"} {
+			if _, err := m.SaveAnswer(uuid.MustParse(p), uuid.MustParse("77872cdd-db89-451d-87d3-0804e6f99e5e"), time.Unix(1613388007, 0), c); err != nil {
+				t.Fatalf("Saving answer failed: %v", err)
+			}
+		}
+
+		_, teams, _, _, err := m.GetResults(organiserId, c)
+		if err != nil {
+			t.Fatalf("Getting results failed: %v", err)
+		} else if len(teams) != 2 {
+			t.Fatalf("Expected 2 teams, got %d", len(teams))
+		}
+		if teams[0].Team.Name != "Red" || teams[0].Points() != points || teams[0].Place() != 1 {
+			t.Errorf("Expected Red to win with %v points with %s scoring, got %s with %v points", points, scoring, teams[0].Team.Name, teams[0].Points())
+		}
+		if teams[1].Points() != 0 || teams[1].Place() != 2 {
+			t.Errorf("Expected Blue to come second with no points with %s scoring, got %v points", scoring, teams[1].Points())
+		}
+	}
+}
+
+func TestModel_selfPaced(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	s, organiser, err := m.CreateSession("teacher", "abcdef", SessionOptions{SelfPaced: true, Deadline: time.Unix(1613400000, 0)}, time.Unix(1613390000, 0), c)
+	if err != nil {
+		t.Fatalf("Creating a self-paced session failed: %v", err)
+	}
+	alice, err := m.RegisterPlayer("alice", "", s.Code, time.Unix(1613390001, 0), c)
+	if err != nil {
+		t.Fatalf("Registering a player failed: %v", err)
+	}
+	bob, err := m.RegisterPlayer("bob", "", s.Code, time.Unix(1613390002, 0), c)
+	if err != nil {
+		t.Fatalf("Registering a player failed: %v", err)
+	}
+
+	if err := m.NextQuestion(organiser.ID, time.Unix(1613390003, 0), c); !errors.Is(err, NotLive) {
+		t.Fatalf("Expected the organiser not to drive a self-paced session, got: %v", err)
+	}
+
+	if err := m.NextOwnQuestion(alice.ID, time.Unix(1613390010, 0), c); err != nil {
+		t.Fatalf("Opening the first question failed: %v", err)
+	}
+	if su, err := m.GetOwnQuestionStateUpdate(alice.ID, time.Unix(1613390020, 0), c); err != nil {
+		t.Fatalf("Getting own question state update failed: %v", err)
+	} else if su.Question == nil || su.Question.Title != "The WWII ended in:" || su.Question.RemainingTime != 20000 {
+		t.Fatalf("Expected the first question with its own timer, got %#v", su)
+	}
+	if su, err := m.GetOwnQuestionStateUpdate(bob.ID, time.Unix(1613390020, 0), c); err != nil {
+		t.Fatalf("Getting own question state update failed: %v", err)
+	} else if su.Question != nil || su.Break != nil {
+		t.Fatalf("Expected no question for a player, who has not started yet, got %#v", su)
+	}
+
+	if _, err := m.SaveAnswer(alice.ID, uuid.MustParse("7be00601-d316-46ef-842d-d7b25235905f"), time.Unix(1613390021, 0), c); err != nil {
+		t.Fatalf("Saving answer failed: %v", err)
+	}
+	if su, err := m.GetOwnQuestionStateUpdate(alice.ID, time.Unix(1613390022, 0), c); err != nil {
+		t.Fatalf("Getting own question state update failed: %v", err)
+	} else if su.Break == nil {
+		t.Fatalf("Expected the answered question to be revealed, got %#v", su)
+	}
+
+	results, _, _, _, err := m.GetResults(organiser.ID, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	} else if len(results) != 2 || results[0].Player.Name != "alice" || results[0].Points() != 1 || results[0].Progress() != 1 || results[1].Progress() != 0 {
+		t.Fatalf("Unexpected results before everyone has finished: %#v", results)
+	}
+
+	if err := m.NextOwnQuestion(alice.ID, time.Unix(1613390030, 0), c); err != nil {
+		t.Fatalf("Opening the second question failed: %v", err)
+	}
+	if err := m.NextOwnQuestion(alice.ID, time.Unix(1613390040, 0), c); !errors.Is(err, NoNextQuestion) {
+		t.Fatalf("Expected no question after the last one, got: %v", err)
+	}
+
+	if advanced, _, err := m.AutoAdvance(s.ID, time.Unix(1613400000, 0), c); err != nil {
+		t.Fatalf("Closing the session at its deadline failed: %v", err)
+	} else if !advanced {
+		t.Fatalf("The session has not been closed at its deadline")
+	}
+	if err := m.NextOwnQuestion(bob.ID, time.Unix(1613400001, 0), c); !errors.Is(err, QuestionClosed) {
+		t.Fatalf("Expected the closed session to refuse opening questions, got: %v", err)
+	}
+}
+
+func TestModel_GetResults_multiple(t *testing.T) {
+	var expected = map[bool]map[string]float64{
+		false: {"Bob": 0, "Lisa ❤️": 1, "Petr": 0},
+		true:  {"Bob": 0.5, "Lisa ❤️": 1, "Petr": 0},
+	}
+	for partialCredit, points := range expected {
+		m := newTestModelWithData(t)
+		c := context.Background()
+		m.c.Question.UpdateOneID(uuid.MustParse("65b848a8-7d0e-4b16-96aa-c6b89bda6657")).SetType(question.TypeMultiple).ExecX(c)
+		m.c.Choice.UpdateOneID(uuid.MustParse("9bd328e9-7a6f-4c39-9d91-7302a5916eeb")).SetCorrect(true).ExecX(c)
+		m.c.Session.UpdateOneID(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")).SetPartialCredit(partialCredit).ExecX(c)
+
+		// Bob has already picked one of the two correct choices and Petr a wrong one, Lisa picks both correct ones
+		choiceIds := []uuid.UUID{uuid.MustParse("7be00601-d316-46ef-842d-d7b25235905f"), uuid.MustParse("9bd328e9-7a6f-4c39-9d91-7302a5916eeb"), uuid.MustParse("7be00601-d316-46ef-842d-d7b25235905f")}
+		if answers, err := m.SaveAnswers(uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0"), choiceIds, time.Unix(1613388000, 0), c); err != nil {
+			t.Fatalf("Saving answers failed: %v", err)
+		} else if len(answers) != 2 {
+			t.Fatalf("Expected duplicate choices to be saved once, got %d answers", len(answers))
+		}
+
+		results, _, _, _, err := m.GetResults(uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1"), c)
+		if err != nil {
+			t.Fatalf("Getting results failed: %v", err)
+		}
+		for _, r := range results {
+			if p, ok := points[r.Player.Name]; !ok || p != r.Points() {
+				t.Errorf("Player %s has %v points with partial credit %v, expected %v", r.Player.Name, r.Points(), partialCredit, p)
+			}
+		}
+	}
+}
+
+func TestModel_textAnswers(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+	bobId := uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf")
+	lisaId := uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0")
+	petrId := uuid.MustParse("cd0afe61-2c89-473f-9269-bbcb50016941")
+
+	// Washington DC becomes the only accepted answer
+	questionId := uuid.MustParse("adb9b601-9ae7-4d91-8998-968d9848eeb4")
+	m.c.Question.UpdateOneID(questionId).SetType(question.TypeText).SetTolerance(1).ExecX(c)
+	m.c.Choice.Delete().Where(choice.HasQuestionWith(question.ID(questionId)), choice.Correct(false)).ExecX(c)
+
+	if err := m.NextQuestion(organiserId, time.Unix(1613388005, 0), c); err != nil {
+		t.Fatalf("Closing the first question failed: %v", err)
+	}
+	if err := m.NextQuestion(organiserId, time.Unix(1613388006, 0), c); err != nil {
+		t.Fatalf("Asking the second question failed: %v", err)
+	}
+	if su, err := m.GetQuestionStateUpdate(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613388006, 0), c); err != nil {
+		t.Fatalf("Getting question state update failed: %v", err)
+	} else if su.Question == nil || su.Question.Type != "text" || len(su.Question.Answers) != 0 {
+		t.Fatalf("Expected a text question without revealed answers, got %#v", su.Question)
+	}
+
+	if _, err := m.SaveAnswer(bobId, uuid.MustParse("77872cdd-db89-451d-87d3-0804e6f99e5e"), time.Unix(1613388007, 0), c); !errors.Is(err, InvalidAnswer) {
+		t.Fatalf("Expected picking a choice of a text question to be refused, got: %v", err)
+	}
+	if _, err := m.SaveTextAnswer(bobId, "  ", time.Unix(1613388007, 0), c); !errors.Is(err, InvalidAnswer) {
+		t.Fatalf("Expected an empty text answer to be refused, got: %v", err)
+	}
+	for player, text := range map[uuid.UUID]string{bobId: "washington d.c.", lisaId: "Washingtn DC", petrId: "Washington"} {
+		if _, err := m.SaveTextAnswer(player, text, time.Unix(1613388007, 0), c); err != nil {
+			t.Fatalf("Saving text answer failed: %v", err)
+		}
+	}
+
+	if err := m.AcceptAnswer(organiserId, "Washington", time.Unix(1613388008, 0), c); !errors.Is(err, NoSuchEntity) {
+		t.Fatalf("Expected accepting an answer while the question is open to fail, got: %v", err)
+	}
+	if err := m.NextQuestion(organiserId, time.Unix(1613388009, 0), c); err != nil {
+		t.Fatalf("Closing the second question failed: %v", err)
+	}
+
+	results, _, _, _, err := m.GetResults(organiserId, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	var expected = map[string]float64{"Bob": 2, "Lisa ❤️": 1, "Petr": 0}
+	for _, r := range results {
+		if points, ok := expected[r.Player.Name]; !ok || points != r.Points() {
+			t.Errorf("Player %s has %v points, expected %v", r.Player.Name, r.Points(), points)
+		}
+	}
+
+	if err := m.AcceptAnswer(organiserId, "WASHINGTON", time.Unix(1613388010, 0), c); err != nil {
+		t.Fatalf("Accepting an answer failed: %v", err)
+	}
+	results, _, _, _, err = m.GetResults(organiserId, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Player.Name == "Petr" && r.Points() != 1 {
+			t.Errorf("Expected Petr to score for the accepted answer, got %v points", r.Points())
+		}
+	}
+}
+
+func TestModel_numberAnswers(t *testing.T) {
+	var expected = map[float64]map[string]float64{
+		0:  {"Bob": 2, "Lisa ❤️": 1, "Petr": 0},
+		10: {"Bob": 1.8, "Lisa ❤️": 0.8, "Petr": 0},
+	}
+	for margin, points := range expected {
+		m := newTestModelWithData(t)
+		c := context.Background()
+		organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+
+		// the second question asks for the number of bones in the human body
+		questionId := uuid.MustParse("adb9b601-9ae7-4d91-8998-968d9848eeb4")
+		m.c.Question.UpdateOneID(questionId).SetType(question.TypeNumber).SetValue(206).SetMargin(margin).ExecX(c)
+		m.c.Choice.Delete().Where(choice.HasQuestionWith(question.ID(questionId))).ExecX(c)
+
+		if err := m.NextQuestion(organiserId, time.Unix(1613388005, 0), c); err != nil {
+			t.Fatalf("Closing the first question failed: %v", err)
+		}
+		if err := m.NextQuestion(organiserId, time.Unix(1613388006, 0), c); err != nil {
+			t.Fatalf("Asking the second question failed: %v", err)
+		}
+		if _, err := m.SaveTextAnswer(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf"), "206", time.Unix(1613388007, 0), c); !errors.Is(err, InvalidAnswer) {
+			t.Fatalf("Expected a text answer to a number question to be refused, got: %v", err)
+		}
+		for player, guess := range map[string]float64{"f8cd85a4-8b46-4145-abaf-df924a7719cf": 208, "321f3bb4-f789-49db-ad14-45299a4725a0": 204, "cd0afe61-2c89-473f-9269-bbcb50016941": 300} {
+			if _, err := m.SaveNumberAnswer(uuid.MustParse(player), guess, time.Unix(1613388007, 0), c); err != nil {
+				t.Fatalf("Saving number answer failed: %v", err)
+			}
+		}
+		if err := m.NextQuestion(organiserId, time.Unix(1613388009, 0), c); err != nil {
+			t.Fatalf("Closing the second question failed: %v", err)
+		}
+
+		if su, err := m.GetQuestionStateUpdate(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613388010, 0), c); err != nil {
+			t.Fatalf("Getting question state update failed: %v", err)
+		} else if su.Break == nil || su.Break.Number == nil || su.Break.Number.Value != 206 || !reflect.DeepEqual(su.Break.Number.Guesses, []float64{204, 208, 300}) {
+			t.Fatalf("Expected the value and the guesses to be revealed, got %#v", su.Break)
+		}
+
+		results, _, _, _, err := m.GetResults(organiserId, c)
+		if err != nil {
+			t.Fatalf("Getting results failed: %v", err)
+		}
+		for _, r := range results {
+			if p, ok := points[r.Player.Name]; !ok || p != r.Points() {
+				t.Errorf("Player %s has %v points with margin %v, expected %v", r.Player.Name, r.Points(), margin, p)
+			}
+		}
+	}
+}
+
+func TestModel_ordering(t *testing.T) {
+	var expected = map[bool]map[string]float64{
+		false: {"Bob": 2, "Lisa ❤️": 0, "Petr": 0},
+		true:  {"Bob": 2, "Lisa ❤️": 0.83, "Petr": 0},
+	}
+	var correct = []uuid.UUID{
+		uuid.MustParse("01819d92-fb00-4543-827e-b44f8ba17854"),
+		uuid.MustParse("d438e6de-a142-4cc5-9c0f-1bb3a37786c0"),
+		uuid.MustParse("77872cdd-db89-451d-87d3-0804e6f99e5e"),
+		uuid.MustParse("4fd20819-525c-4172-8e4e-7f82585b6c23"),
+	}
+	for partialCredit, points := range expected {
+		m := newTestModelWithData(t)
+		c := context.Background()
+		organiserId := uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1")
+		m.c.Session.UpdateOneID(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")).SetPartialCredit(partialCredit).ExecX(c)
+
+		// the choices of the second question are to be ordered as listed in correct
+		m.c.Question.UpdateOneID(uuid.MustParse("adb9b601-9ae7-4d91-8998-968d9848eeb4")).SetType(question.TypeOrdering).ExecX(c)
+		for i, id := range correct {
+			m.c.Choice.UpdateOneID(id).SetOrder(i).ExecX(c)
+		}
+
+		if err := m.NextQuestion(organiserId, time.Unix(1613388005, 0), c); err != nil {
+			t.Fatalf("Closing the first question failed: %v", err)
+		}
+		if err := m.NextQuestion(organiserId, time.Unix(1613388006, 0), c); err != nil {
+			t.Fatalf("Asking the second question failed: %v", err)
+		}
+		if su, err := m.GetQuestionStateUpdate(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), time.Unix(1613388006, 0), c); err != nil {
+			t.Fatalf("Getting question state update failed: %v", err)
+		} else if su.Question == nil || len(su.Question.Answers) != len(correct) {
+			t.Fatalf("Expected an ordering question with all its choices, got %#v", su.Question)
+		} else {
+			var presented = make([]uuid.UUID, 0, len(correct))
+			for _, a := range su.Question.Answers {
+				presented = append(presented, uuid.MustParse(a.ID))
+			}
+			if reflect.DeepEqual(presented, correct) {
+				t.Errorf("The choices have been presented in the correct order")
+			}
+		}
+
+		if _, err := m.SaveAnswer(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf"), correct[0], time.Unix(1613388007, 0), c); !errors.Is(err, InvalidAnswer) {
+			t.Fatalf("Expected picking a single choice of an ordering question to be refused, got: %v", err)
+		}
+		if _, err := m.SaveOrder(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf"), correct[:3], time.Unix(1613388007, 0), c); !errors.Is(err, InvalidAnswer) {
+			t.Fatalf("Expected an incomplete order to be refused, got: %v", err)
+		}
+		var orders = map[string][]uuid.UUID{
+			"f8cd85a4-8b46-4145-abaf-df924a7719cf": correct,
+			"321f3bb4-f789-49db-ad14-45299a4725a0": {correct[0], correct[1], correct[3], correct[2]},
+			"cd0afe61-2c89-473f-9269-bbcb50016941": {correct[3], correct[2], correct[1], correct[0]},
+		}
+		for player, order := range orders {
+			if _, err := m.SaveOrder(uuid.MustParse(player), order, time.Unix(1613388007, 0), c); err != nil {
+				t.Fatalf("Saving order failed: %v", err)
+			}
+		}
+
+		results, _, _, _, err := m.GetResults(organiserId, c)
+		if err != nil {
+			t.Fatalf("Getting results failed: %v", err)
+		}
+		for _, r := range results {
+			if p, ok := points[r.Player.Name]; !ok || p != r.Points() {
+				t.Errorf("Player %s has %v points with partial credit %v, expected %v", r.Player.Name, r.Points(), partialCredit, p)
+			}
+		}
+	}
+}
+
+func TestModel_shuffle(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	s, organiser, err := m.CreateSession("teacher", "abcdef", SessionOptions{ShuffleQuestions: true, ShuffleChoices: session.ShuffleChoicesPlayer}, time.Unix(1613390000, 0), c)
+	if err != nil {
+		t.Fatalf("Creating a shuffled session failed: %v", err)
+	}
+	for i, name := range []string{"alice", "bob"} {
+		if _, err := m.RegisterPlayer(name, "", s.Code, time.Unix(1613390001+int64(i), 0), c); err != nil {
+			t.Fatalf("Registering a player failed: %v", err)
+		}
+	}
+
+	// questions are asked in the order given by the session's ID, each of them once
+	var expected = m.c.Question.Query().Where(question.HasGameWith(game.HasSessionsWith(session.ID(s.ID)))).AllX(c)
+	sort.Slice(expected, func(i, j int) bool {
+		return shuffleKey(s.ID[:], expected[i].ID[:]) < shuffleKey(s.ID[:], expected[j].ID[:])
+	})
+	for i, q := range expected {
+		if err := m.NextQuestion(organiser.ID, time.Unix(1613390010+int64(10*i), 0), c); err != nil {
+			t.Fatalf("Asking question %d failed: %v", i, err)
+		}
+		su, err := m.GetQuestionStateUpdate(s.ID, time.Unix(1613390011+int64(10*i), 0), c)
+		if err != nil {
+			t.Fatalf("Getting question state update failed: %v", err)
+		} else if su.Question == nil || su.Question.Title != q.Title {
+			t.Fatalf("Expected question %q to be asked as number %d, got %#v", q.Title, i, su.Question)
+		}
+
+		// every player gets their own order of the same choices, which stays the same when asked again
+		again, err := m.GetQuestionStateUpdate(s.ID, time.Unix(1613390012+int64(10*i), 0), c)
+		if err != nil {
+			t.Fatalf("Getting question state update failed: %v", err)
+		}
+		for _, name := range []string{"alice", "bob"} {
+			var answers = su.Personalise(name, false).Question.Answers
+			if !reflect.DeepEqual(answers, su.Question.Shuffled[name]) || !reflect.DeepEqual(answers, again.Personalise(name, false).Question.Answers) {
+				t.Errorf("Expected %s to get the same shuffled choices every time, got %#v", name, answers)
+			}
+			if len(answers) != len(su.Question.Answers) {
+				t.Errorf("Expected %s to get all %d choices, got %#v", name, len(su.Question.Answers), answers)
+			}
+		}
+		if !reflect.DeepEqual(su.Personalise("teacher", true).Question.Answers, su.Question.Answers) {
+			t.Errorf("Expected the organiser to get the order shared by the session")
+		}
+
+		if err := m.NextQuestion(organiser.ID, time.Unix(1613390015+int64(10*i), 0), c); err != nil {
+			t.Fatalf("Closing question %d failed: %v", i, err)
+		}
+	}
+	if err := m.NextQuestion(organiser.ID, time.Unix(1613390100, 0), c); !errors.Is(err, NoNextQuestion) {
+		t.Fatalf("Expected no question after the last one, got: %v", err)
+	}
+}
+
+func TestShuffleAnswers(t *testing.T) {
+	var answers = []rtcomm.Answer{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+	var seen = make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		var seed = []byte{byte(i)}
+		var shuffled = shuffleAnswers(answers, seed, true)
+		if !reflect.DeepEqual(shuffled, shuffleAnswers(answers, seed, true)) {
+			t.Fatalf("Expected the same seed to give the same order")
+		}
+		var ids string
+		for _, a := range shuffled {
+			ids += a.ID
+		}
+		if ids == "abcd" {
+			t.Fatalf("Expected the correct order never to be presented")
+		}
+		seen[ids] = true
+	}
+	if len(seen) < 10 {
+		t.Errorf("Expected various orders for various seeds, got %v", seen)
+	}
+	if answers[0].ID != "a" || answers[3].ID != "d" {
+		t.Errorf("Expected the original answers to be left alone, got %#v", answers)
+	}
+}
+
+func TestModel_pool(t *testing.T) {
+	m := newTestModel(t)
+	c := context.Background()
+	var g gameCreator.Game
+	for i, category := range []string{"A", "A", "A", "B", "B", "C"} {
+		g.Questions = append(g.Questions, gameCreator.Question{
+			Title:    fmt.Sprintf("Question %d", i),
+			Length:   10000,
+			Category: category,
+			Choices:  []gameCreator.Choice{{Title: "Yes", Correct: true}, {Title: "No"}},
+		})
+	}
+	created, err := m.CreateGame(g, "Bank", "me", c)
+	if err != nil {
+		t.Fatalf("Creating game failed: %v", err)
+	}
+
+	s, organiser, err := m.CreateSession("teacher", created.Code, SessionOptions{PoolSize: 3, PoolByCategory: true}, time.Unix(1613390000, 0), c)
+	if err != nil {
+		t.Fatalf("Creating a session with a pool failed: %v", err)
+	}
+	pool, err := m.GetSessionQuestions(s.ID, c)
+	if err != nil {
+		t.Fatalf("Getting session questions failed: %v", err)
+	}
+	var categories = make(map[string]int)
+	for _, q := range pool {
+		categories[q.Category]++
+	}
+	if len(pool) != 3 || categories["A"] != 1 || categories["B"] != 1 || categories["C"] != 1 {
+		t.Fatalf("Expected one question of each category, got %v", categories)
+	}
+
+	// only the questions of the pool are asked
+	var asked = make(map[uuid.UUID]bool)
+	for i := 0; ; i++ {
+		if err := m.NextQuestion(organiser.ID, time.Unix(1613390010+int64(2*i), 0), c); errors.Is(err, NoNextQuestion) {
+			break
+		} else if err != nil {
+			t.Fatalf("Moving to the next question failed: %v", err)
+		}
+		if aq, err := m.c.AskedQuestion.Query().Where(askedquestion.HasSessionWith(session.ID(s.ID)), askedquestion.EndedIsNil()).WithQuestion().Only(c); err == nil {
+			asked[aq.Edges.Question.ID] = true
+		} else if !ent.IsNotFound(err) {
+			t.Fatalf("Getting the asked question failed: %v", err)
+		}
+	}
+	if len(asked) != len(pool) {
+		t.Errorf("Expected %d questions to be asked, got %d", len(pool), len(asked))
+	}
+	for _, q := range pool {
+		if !asked[q.ID] {
+			t.Errorf("Question %q of the pool has not been asked", q.Title)
+		}
+	}
+
+	game, err := m.GetGameWithQuestionsAndChoices(created.ID, c)
+	if err != nil {
+		t.Fatalf("Getting game failed: %v", err)
+	}
+	var outside uuid.UUID
+	for _, q := range game.Edges.Questions {
+		if !asked[q.ID] {
+			outside = q.ID
+		}
+	}
+	if err := m.AskQuestion(organiser.ID, outside, time.Unix(1613390100, 0), c); !errors.Is(err, NoSuchEntity) {
+		t.Errorf("Expected a question outside the pool to be refused, got: %v", err)
+	}
+}
+
+func TestModel_rounds(t *testing.T) {
+	m := newTestModel(t)
+	c := context.Background()
+	var g = gameCreator.Game{Rounds: []gameCreator.Round{{Title: "Capitals", Intro: "Of *Europe*"}, {Title: "Rivers"}}}
+	for i, round := range []int{0, 1, 1, 2} {
+		g.Questions = append(g.Questions, gameCreator.Question{
+			Title:   fmt.Sprintf("Question %d", i),
+			Length:  10000,
+			Round:   round,
+			Choices: []gameCreator.Choice{{Title: "Yes", Correct: true}, {Title: "No"}},
+		})
+	}
+	created, err := m.CreateGame(g, "Pub quiz", "me", c)
+	if err != nil {
+		t.Fatalf("Creating game failed: %v", err)
+	}
+	game, err := m.GetGameWithQuestionsAndChoices(created.ID, c)
+	if err != nil {
+		t.Fatalf("Getting game failed: %v", err)
+	}
+
+	s, organiser, err := m.CreateSession("teacher", created.Code, SessionOptions{}, time.Unix(1613390000, 0), c)
+	if err != nil {
+		t.Fatalf("Creating a session failed: %v", err)
+	}
+	var players []*ent.Player
+	for i, name := range []string{"alice", "bob"} {
+		if p, err := m.RegisterPlayer(name, "", s.Code, time.Unix(1613390001+int64(i), 0), c); err == nil {
+			players = append(players, p)
+		} else {
+			t.Fatalf("Registering a player failed: %v", err)
+		}
+	}
+
+	// alice answers the first round correctly, bob the second one
+	var answering = []int{-1, 0, 0, 1}
+	var breaks []*rtcomm.BreakUpdate
+	for i, q := range game.Edges.Questions {
+		if err := m.NextQuestion(organiser.ID, time.Unix(1613390010+int64(10*i), 0), c); err != nil {
+			t.Fatalf("Asking question %d failed: %v", i, err)
+		}
+		su, err := m.GetQuestionStateUpdate(s.ID, time.Unix(1613390011+int64(10*i), 0), c)
+		if err != nil {
+			t.Fatalf("Getting question state update failed: %v", err)
+		} else if q.Edges.Round != nil && su.Question.Round != q.Edges.Round.Title || q.Edges.Round == nil && su.Question.Round != "" {
+			t.Errorf("Expected question %d to show its round, got %q", i, su.Question.Round)
+		}
+		if p := answering[i]; p >= 0 {
+			var correct = q.Edges.Choices[0]
+			if _, err := m.SaveAnswer(players[p].ID, correct.ID, time.Unix(1613390012+int64(10*i), 0), c); err != nil {
+				t.Fatalf("Saving answer failed: %v", err)
+			}
+		}
+		if err := m.NextQuestion(organiser.ID, time.Unix(1613390015+int64(10*i), 0), c); err != nil {
+			t.Fatalf("Closing question %d failed: %v", i, err)
+		}
+		if su, err := m.GetQuestionStateUpdate(s.ID, time.Unix(1613390016+int64(10*i), 0), c); err == nil && su.Break != nil {
+			breaks = append(breaks, su.Break)
+		} else {
+			t.Fatalf("Getting the break failed: %v", err)
+		}
+	}
+
+	if breaks[0].RoundResults != nil || breaks[0].NextRound == nil || breaks[0].NextRound.Title != "Capitals" || breaks[0].NextRound.IntroHTML != "Of <em>Europe</em>" {
+		t.Errorf("Expected the first round to be introduced after the first question, got %#v", breaks[0])
+	}
+	if breaks[1].RoundResults != nil || breaks[1].NextRound != nil {
+		t.Errorf("Expected no round to end or start in the middle of a round, got %#v", breaks[1])
+	}
+	if rr := breaks[2].RoundResults; rr == nil || rr.Title != "Capitals" || rr.Leaderboard[0].Name != "alice" || rr.Leaderboard[1].Points != 0 {
+		t.Errorf("Expected alice to win the first round, got %#v", rr)
+	} else if me := (rtcomm.StateUpdate{Break: breaks[2]}).Personalise("bob", false).Break.RoundResults.Me; me == nil || me.Place != 2 {
+		t.Errorf("Expected bob to see their place in the round, got %#v", me)
+	}
+	if breaks[2].NextRound == nil || breaks[2].NextRound.Title != "Rivers" {
+		t.Errorf("Expected the second round to be introduced, got %#v", breaks[2].NextRound)
+	}
+	if rr := breaks[3].RoundResults; rr == nil || rr.Title != "Rivers" || rr.Leaderboard[0].Name != "bob" || breaks[3].NextRound != nil {
+		t.Errorf("Expected bob to win the last round, got %#v", breaks[3])
+	}
+
+	results, _, _, _, err := m.GetResults(organiser.ID, c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	var capitals, rivers = game.Edges.Questions[1].Edges.Round.ID, game.Edges.Questions[3].Edges.Round.ID
+	for _, r := range results {
+		if r.RoundPoints(capitals)+r.RoundPoints(rivers) != r.Points() {
+			t.Errorf("Expected the round points of %s to add up to %v, got %v and %v", r.Player.Name, r.Points(), r.RoundPoints(capitals), r.RoundPoints(rivers))
+		}
+		if r.Player.Name == "alice" && (r.RoundPoints(capitals) == 0 || r.RoundPoints(rivers) != 0) {
+			t.Errorf("Expected alice to score in the first round only, got %v and %v", r.RoundPoints(capitals), r.RoundPoints(rivers))
+		}
+	}
+}
+
+func TestModel_explanations(t *testing.T) {
+	m := newTestModel(t)
+	c := context.Background()
+	var g = gameCreator.Game{Questions: []gameCreator.Question{{
+		Title:       "H2O is",
+		Length:      10000,
+		Explanation: "Two *hydrogens* and one oxygen",
+		Choices:     []gameCreator.Choice{{Title: "Water", Correct: true}, {Title: "Salt", Feedback: "Salt is NaCl"}},
+	}}}
+	created, err := m.CreateGame(g, "Chemistry", "me", c)
+	if err != nil {
+		t.Fatalf("Creating game failed: %v", err)
+	}
+	s, organiser, err := m.CreateSession("teacher", created.Code, SessionOptions{}, time.Unix(1613390000, 0), c)
+	if err != nil {
+		t.Fatalf("Creating a session failed: %v", err)
+	}
+	if err := m.NextQuestion(organiser.ID, time.Unix(1613390010, 0), c); err != nil {
+		t.Fatalf("Asking the question failed: %v", err)
+	}
+	if err := m.NextQuestion(organiser.ID, time.Unix(1613390015, 0), c); err != nil {
+		t.Fatalf("Closing the question failed: %v", err)
+	}
+
+	su, err := m.GetQuestionStateUpdate(s.ID, time.Unix(1613390016, 0), c)
+	if err != nil {
+		t.Fatalf("Getting the break failed: %v", err)
+	}
+	if su.Break.ExplanationHTML != "Two <em>hydrogens</em> and one oxygen" {
+		t.Errorf("Expected the explanation in the break, got %q", su.Break.ExplanationHTML)
+	}
+	if su.Break.Answers[0].FeedbackHTML != "" || su.Break.Answers[1].FeedbackHTML != "Salt is NaCl" {
+		t.Errorf("Expected feedback on the second choice only, got %#v", su.Break.Answers)
+	}
+}
+
+func TestModel_UpdateGame(t *testing.T) {
+	m := newTestModel(t)
+	c := context.Background()
+	var g = gameCreator.Game{
+		Questions: []gameCreator.Question{{Title: "H2O is", Length: 10000, Image: "water.png", Choices: []gameCreator.Choice{{Title: "Water", Correct: true}, {Title: "Salt"}}}},
+		Images:    map[string]gameCreator.Image{"water.png": {Type: "image/png", Data: []byte{1}}},
+	}
+	created, err := m.CreateGame(g, "Chemistry", "me", c)
+	if err != nil {
+		t.Fatalf("Creating game failed: %v", err)
+	} else if created.EditToken == uuid.Nil {
+		t.Fatalf("Expected the game to get an edit token")
+	}
+	var token = created.EditToken
+
+	// the game may only be edited with its token
+	if _, _, err := m.ExportGame(created.ID, uuid.New(), c); !errors.Is(err, Forbidden) {
+		t.Errorf("Expected exporting with a wrong token to be refused, got: %v", err)
+	}
+	if _, err := m.UpdateGame(created.ID, uuid.New(), g, "Chemistry", "me", c); !errors.Is(err, Forbidden) {
+		t.Errorf("Expected updating with a wrong token to be refused, got: %v", err)
+	}
+
+	// a game that has not been played is edited in place
+	exported, _, err := m.ExportGame(created.ID, token, c)
+	if err != nil {
+		t.Fatalf("Exporting game failed: %v", err)
+	} else if !reflect.DeepEqual(exported, g) {
+		t.Fatalf("Expected the exported game to equal the created one, got %#v", exported)
+	}
+	exported.Questions[0].Title = "H₂O is"
+	if updated, err := m.UpdateGame(created.ID, token, exported, "Chemistry 101", "me", c); err != nil {
+		t.Fatalf("Updating game failed: %v", err)
+	} else if updated.ID != created.ID || updated.Code != created.Code || updated.Name != "Chemistry 101" {
+		t.Errorf("Expected the game to be updated in place, got %#v", updated)
+	}
+
+	// a played game gets a new version with the same code
+	s, _, err := m.CreateSession("teacher", created.Code, SessionOptions{}, time.Unix(1613390000, 0), c)
+	if err != nil {
+		t.Fatalf("Creating a session failed: %v", err)
+	}
+	exported.Questions[0].Choices = append(exported.Questions[0].Choices, gameCreator.Choice{Title: "Sugar"})
+	version, err := m.UpdateGame(created.ID, token, exported, "Chemistry 101", "me", c)
+	if err != nil {
+		t.Fatalf("Updating a played game failed: %v", err)
+	} else if version.ID == created.ID || version.Code != created.Code || version.Version != 2 || version.EditToken != token {
+		t.Fatalf("Expected a new version taking over the code and the edit token, got %#v", version)
+	}
+	if previous, err := m.GetGameWithQuestionsAndChoices(created.ID, c); err != nil {
+		t.Fatalf("Getting the previous version failed: %v", err)
+	} else if previous.Code == created.Code || previous.Edges.Next == nil || previous.Edges.Next.ID != version.ID || len(previous.Edges.Questions[0].Edges.Choices) != 2 {
+		t.Errorf("Expected the previous version to be kept as it was, got %#v", previous)
+	}
+	if gameId, err := m.c.Session.Query().Where(session.ID(s.ID)).QueryGame().OnlyID(c); err != nil || gameId != created.ID {
+		t.Errorf("Expected the session to stay with the previous version, got %v, %v", gameId, err)
+	}
+	if again, _, err := m.ExportGame(version.ID, token, c); err != nil {
+		t.Fatalf("Exporting the new version failed: %v", err)
+	} else if !reflect.DeepEqual(again, exported) {
+		t.Errorf("Expected the new version to carry the edited questions and images, got %#v", again)
+	}
+
+	// sessions are created for the latest version only, which is the only one to be edited
+	if s, _, err := m.CreateSession("teacher", created.Code, SessionOptions{}, time.Unix(1613390100, 0), c); err != nil {
+		t.Fatalf("Creating a session failed: %v", err)
+	} else if m.c.Session.Query().Where(session.ID(s.ID)).QueryGame().OnlyIDX(c) != version.ID {
+		t.Errorf("Expected the session to be created for the latest version")
+	}
+	if _, err := m.UpdateGame(created.ID, token, exported, "Chemistry", "me", c); !errors.Is(err, Outdated) {
+		t.Errorf("Expected the previous version to be refused, got: %v", err)
+	}
+}
+
+func TestModel_IssueEditTokens(t *testing.T) {
+	m := newTestModel(t)
+	c := context.Background()
+
+	// games created before edit tokens existed have none
+	legacy := m.c.Game.Create().SetID(uuid.New()).SetName("Chemistry").SetCode("chem").SetCreated(time.Unix(1613390000, 0)).SetAuthor("me").SaveX(c)
+	if _, _, err := m.ExportGame(legacy.ID, uuid.Nil, c); !errors.Is(err, Forbidden) {
+		t.Fatalf("Expected a game without an edit token not to be editable, got: %v", err)
+	}
+	current, err := m.CreateGame(gameCreator.Game{Questions: []gameCreator.Question{{Title: "H2O is", Length: 10000, Choices: []gameCreator.Choice{{Title: "Water", Correct: true}}}}}, "Physics", "me", c)
+	if err != nil {
+		t.Fatalf("Creating game failed: %v", err)
+	}
+
+	issued, err := m.IssueEditTokens(c)
+	if err != nil {
+		t.Fatalf("Issuing edit tokens failed: %v", err)
+	} else if len(issued) != 1 || issued[0].ID != legacy.ID || issued[0].EditToken == uuid.Nil {
+		t.Fatalf("Expected only the legacy game to be issued a token, got %#v", issued)
+	}
+	if _, _, err := m.ExportGame(legacy.ID, issued[0].EditToken, c); err != nil {
+		t.Errorf("Expected the legacy game to be editable with the issued token, got: %v", err)
+	}
+	if token := m.c.Game.GetX(c, current.ID).EditToken; token != current.EditToken {
+		t.Errorf("Expected the token of the other game to stay the same")
+	}
+	if again, err := m.IssueEditTokens(c); err != nil || len(again) != 0 {
+		t.Errorf("Expected no more games to be issued a token, got %d, %v", len(again), err)
+	}
+}
+
+func TestDrawPool(t *testing.T) {
+	var questions []*ent.Question
+	for _, category := range []string{"A", "A", "A", "A", "B", "C"} {
+		questions = append(questions, &ent.Question{ID: uuid.New(), Category: category})
+	}
+	var r = rand.New(rand.NewSource(1))
+	if pool := drawPool(questions, 10, false, r); len(pool) != len(questions) {
+		t.Errorf("Expected all %d questions to be drawn, got %d", len(questions), len(pool))
+	}
+	for i := 0; i < 20; i++ {
+		var categories = make(map[string]int)
+		var unique = make(map[uuid.UUID]bool)
+		for _, q := range drawPool(questions, 5, true, r) {
+			categories[q.Category]++
+			unique[q.ID] = true
+		}
+		if len(unique) != 5 || categories["A"] != 3 || categories["B"] != 1 || categories["C"] != 1 {
+			t.Fatalf("Expected the questions missing in B and C to be drawn from A, got %v", categories)
+		}
+	}
+}
+
+func TestModel_poll(t *testing.T) {
+	m := newTestModelWithData(t)
+	c := context.Background()
+	var choices = []uuid.UUID{
+		uuid.MustParse("7be00601-d316-46ef-842d-d7b25235905f"),
+		uuid.MustParse("9bd328e9-7a6f-4c39-9d91-7302a5916eeb"),
+		uuid.MustParse("b88b7f4e-1b17-49ea-8e90-cf42ae4e0f09"),
+		uuid.MustParse("5155b997-eb2c-4cd0-a067-2bb01379730f"),
+	}
+	// Bob and Petr have already voted for a single choice of the first question, which becomes a poll
+	m.c.Question.UpdateOneID(uuid.MustParse("65b848a8-7d0e-4b16-96aa-c6b89bda6657")).SetType(question.TypePoll).SetVotes(2).ExecX(c)
+	for i, id := range choices {
+		m.c.Choice.UpdateOneID(id).SetOrder(i).ExecX(c)
+	}
+
+	if _, err := m.SaveAnswers(uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0"), choices[:3], time.Unix(1613388000, 0), c); !errors.Is(err, InvalidAnswer) {
+		t.Fatalf("Expected voting for more choices than allowed to be refused, got: %v", err)
+	}
+	if _, err := m.SaveAnswers(uuid.MustParse("321f3bb4-f789-49db-ad14-45299a4725a0"), choices[:2], time.Unix(1613388000, 0), c); err != nil {
+		t.Fatalf("Voting failed: %v", err)
+	}
+
+	var expected = []uint64{2, 1, 1, 0}
+	if su, err := m.GetProgressStateUpdate(uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12"), c); err != nil {
+		t.Fatalf("Getting progress failed: %v", err)
+	} else if su.Progress == nil || len(su.Progress.Votes) != len(expected) {
+		t.Fatalf("Expected the votes to be part of the progress, got %#v", su.Progress)
+	} else {
+		for i, v := range su.Progress.Votes {
+			if v.Count != expected[i] || v.Correct {
+				t.Errorf("Choice %s has %d votes and correct set to %v, expected %d votes and not correct", v.Title, v.Count, v.Correct, expected[i])
+			}
+		}
+	}
+
+	results, _, _, _, err := m.GetResults(uuid.MustParse("fccc652f-e674-4c4f-9d45-6938090d3df1"), c)
+	if err != nil {
+		t.Fatalf("Getting results failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Points() != 0 {
+			t.Errorf("Player %s has %v points for a poll", r.Player.Name, r.Points())
+		}
+	}
+
+	if polls, err := m.GetPollResults(uuid.MustParse("f8cd85a4-8b46-4145-abaf-df924a7719cf"), c); err != nil {
+		t.Fatalf("Getting poll results failed: %v", err)
+	} else if len(polls) != 1 || !reflect.DeepEqual(polls[0].Votes, expected) || polls[0].Voters != 3 {
+		t.Fatalf("Expected a single poll with votes %v from 3 players, got %#v", expected, polls)
+	}
+}
+
+func TestModel_CreateGame_images(t *testing.T) {
+	m := newTestModel(t)
+	c := context.Background()
+	var g = gameCreator.Game{
+		Questions: []gameCreator.Question{{
+			Title:  "Which flag is French?",
+			Length: 10000,
+			Image:  "flags.png",
+			Choices: []gameCreator.Choice{
+				{Image: "fr.png", Correct: true},
+				{Title: "Italy", Image: "it.png"},
+				{Title: "Germany"},
+			},
+		}},
+		Images: map[string]gameCreator.Image{
+			"flags.png": {Type: "image/png", Data: []byte("flags")},
+			"fr.png":    {Type: "image/png", Data: []byte("fr")},
+			"it.png":    {Type: "image/gif", Data: []byte("it")},
+		},
+	}
+	created, err := m.CreateGame(g, "Flags", "me", c)
+	if err != nil {
+		t.Fatalf("Creating game failed: %v", err)
+	}
+	game, err := m.GetGameWithQuestionsAndChoices(created.ID, c)
+	if err != nil {
+		t.Fatalf("Getting game failed: %v", err)
+	}
+
+	var q = game.Edges.Questions[0]
+	var qu = getQuestionUpdate(&ent.Session{ID: uuid.New(), ShuffleChoices: session.ShuffleChoicesNone}, &ent.AskedQuestion{ID: uuid.New(), Asked: time.Now(), Edges: ent.AskedQuestionEdges{Question: q}}, nil, time.Now())
+	if qu.Image != ImageURL(q.Image) || qu.Image == "" || qu.Answers[0].Image == "" || qu.Answers[2].Image != "" {
+		t.Fatalf("Expected the question and its first two choices to have images, got %#v", qu)
+	}
+
+	var expected = map[uuid.UUID]string{q.Image: "flags", q.Edges.Choices[0].Image: "fr", q.Edges.Choices[1].Image: "it"}
+	for id, data := range expected {
+		if image, err := m.GetImage(id, c); err != nil {
+			t.Fatalf("Getting image failed: %v", err)
+		} else if string(image.Data) != data {
+			t.Errorf("Image %s holds %q, expected %q", id, image.Data, data)
+		}
+	}
+	if _, err := m.GetImage(uuid.New(), c); !errors.Is(err, NoSuchEntity) {
+		t.Errorf("Expected NoSuchEntity for an unknown image, got: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"vkane.cz/tinyquiz/pkg/model/ent"
+	"vkane.cz/tinyquiz/pkg/model/ent/player"
+	"vkane.cz/tinyquiz/pkg/model/ent/session"
+)
+
+var Forbidden = errors.New("the player is not allowed to perform this action")
+var NotLive = errors.New("the session is self-paced and has no common questions to control")
+
+// Checks that the player is an organiser and returns the ID of the session they organise.
+// Returns NoSuchEntity if there is no such player and Forbidden if they are not an organiser.
+//
+// Every action reserved to organisers shall accept the organiser's player ID instead of the session ID
+// and call this within its own transaction before touching anything else.
+func authorizeOrganiser(tx *ent.Tx, playerId uuid.UUID, c context.Context) (uuid.UUID, error) {
+	p, err := tx.Player.Query().Where(player.ID(playerId)).WithSession().Only(c)
+	if ent.IsNotFound(err) {
+		return uuid.Nil, NoSuchEntity
+	} else if err != nil {
+		return uuid.Nil, err
+	}
+	if !p.Organiser {
+		return uuid.Nil, Forbidden
+	}
+	return p.Edges.Session.ID, nil
+}
+
+// Same as authorizeOrganiser, but returns NotLive if the session is self-paced.
+// Used by actions driving the questions common to all players.
+func authorizeLiveOrganiser(tx *ent.Tx, playerId uuid.UUID, c context.Context) (uuid.UUID, error) {
+	sessionId, err := authorizeOrganiser(tx, playerId, c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if selfPaced, err := tx.Session.Query().Where(session.ID(sessionId), session.SelfPaced(true)).Exist(c); err != nil {
+		return uuid.Nil, err
+	} else if selfPaced {
+		return uuid.Nil, NotLive
+	}
+	return sessionId, nil
+}
+
+// Reports whether the edit token permits editing the game.
+// Games created before edit tokens existed have none and cannot be edited until they are issued one, see IssueEditTokens.
+func editable(g *ent.Game, editToken uuid.UUID) bool {
+	return g.EditToken != uuid.Nil && g.EditToken == editToken
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+	"vkane.cz/tinyquiz/pkg/model/ent"
+	"vkane.cz/tinyquiz/pkg/model/ent/askedquestion"
+	"vkane.cz/tinyquiz/pkg/model/ent/player"
+	"vkane.cz/tinyquiz/pkg/model/ent/session"
+	"vkane.cz/tinyquiz/pkg/rtcomm"
+)
+
+// In a self-paced session, every player gets their own asked questions, which they open one by one whenever they like.
+// The timer of each question starts when the player opens it and the question ends for them once they answer it.
+
+// Tells whether the self-paced session s has been finished or its deadline has passed
+func sessionClosed(s *ent.Session, now time.Time) bool {
+	return s.Finished != nil || s.Deadline != nil && !now.Before(*s.Deadline)
+}
+
+// Returns a query for questions asked to the player in a self-paced session, the current (or the last) one first
+func queryOwnAskedQuestion(tx *ent.Tx, playerId uuid.UUID) *ent.AskedQuestionQuery {
+	return tx.AskedQuestion.Query().Where(askedquestion.HasPlayerWith(player.ID(playerId))).Order(ent.Desc(askedquestion.FieldAsked))
+}
+
+// Closes the player's open question in a self-paced session if any and asks them the next one.
+// Returns NoNextQuestion once the player has gone through all the questions
+// and QuestionClosed if the session does not accept answers anymore.
+func (m *Model) NextOwnQuestion(playerId uuid.UUID, now time.Time, c context.Context) error {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	p, err := tx.Player.Query().Where(player.ID(playerId)).WithSession().Only(c)
+	if ent.IsNotFound(err) {
+		return NoSuchEntity
+	} else if err != nil {
+		return err
+	}
+	var s = p.Edges.Session
+	if p.Organiser || !s.SelfPaced {
+		return Forbidden
+	} else if p.Kicked != nil {
+		return Kicked
+	} else if sessionClosed(s, now) {
+		return QuestionClosed
+	}
+
+	var after *ent.Question
+	if current, err := queryOwnAskedQuestion(tx, playerId).WithQuestion().First(c); err == nil {
+		if current.Ended == nil {
+			if err := current.Update().SetEnded(now).Exec(c); err != nil {
+				return err
+			}
+		}
+		after = current.Edges.Question
+	} else if !ent.IsNotFound(err) {
+		return err
+	}
+
+	next, err := nextQuestion(tx, s.ID, after, c)
+	if errors.Is(err, NoNextQuestion) {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return NoNextQuestion
+	} else if err != nil {
+		return err
+	}
+
+	if err := tx.Session.Update().Where(session.ID(s.ID), session.StartedIsNil()).SetStarted(now).Exec(c); err != nil {
+		return err
+	}
+	if _, err := tx.AskedQuestion.Create().SetID(uuid.New()).SetAsked(now).SetSessionID(s.ID).SetQuestion(next).SetPlayerID(playerId).Save(c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Returns the player's open question in a self-paced session or the reveal of the one they have finished last.
+// Returns an empty StateUpdate if they have not started yet and Results once the session is closed.
+func (m *Model) GetOwnQuestionStateUpdate(playerId uuid.UUID, now time.Time, c context.Context) (rtcomm.StateUpdate, error) {
+	tx, err := m.c.BeginTx(c, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return rtcomm.StateUpdate{}, err
+	}
+	defer tx.Commit()
+
+	p, err := tx.Player.Query().Where(player.ID(playerId)).WithSession().Only(c)
+	if ent.IsNotFound(err) {
+		return rtcomm.StateUpdate{}, NoSuchEntity
+	} else if err != nil {
+		return rtcomm.StateUpdate{}, err
+	}
+	var s = p.Edges.Session
+	if sessionClosed(s, now) {
+		return rtcomm.StateUpdate{Results: true}, nil
+	}
+
+	aq, err := queryOwnAskedQuestion(tx, playerId).WithQuestion(func(q *ent.QuestionQuery) { q.WithChoices(orderChoices).WithRound() }).First(c)
+	if ent.IsNotFound(err) {
+		return rtcomm.StateUpdate{}, nil
+	} else if err != nil {
+		return rtcomm.StateUpdate{}, err
+	}
+
+	if aq.Ended == nil && questionTimeLeft(aq, aq.Edges.Question, now) > 0 {
+		var qu = getQuestionUpdate(s, aq, []string{p.Name}, now)
+		return rtcomm.StateUpdate{Question: &qu}, nil
+	}
+	// there is no common leaderboard until the session is closed
+	var bu = rtcomm.BreakUpdate{
+		Leaderboard: []rtcomm.Standing{},
+	}
+	if err := fillAnswerStats(tx, aq, &bu, c); err != nil {
+		return rtcomm.StateUpdate{}, err
+	}
+	fillExplanations(aq.Edges.Question, &bu)
+	return rtcomm.StateUpdate{Break: &bu}, nil
+}
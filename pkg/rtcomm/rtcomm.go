@@ -6,6 +6,7 @@ import (
 )
 
 // TODO associate lock with individual clients to prevent global locking
+// Clients are grouped by session IDs, or by player IDs for updates sent to a single player.
 type Clients struct {
 	sync.RWMutex
 	clients map[uuid.UUID][]chan StateUpdate
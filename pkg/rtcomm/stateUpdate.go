@@ -3,26 +3,165 @@ package rtcomm
 type StateUpdate struct {
 	Players  []Player        `json:"players,omitempty"`
 	Question *QuestionUpdate `json:"question,omitempty"`
+	Progress *ProgressUpdate `json:"progress,omitempty"`
+	Timer    *TimerUpdate    `json:"timer,omitempty"`
 	Break    *BreakUpdate    `json:"break,omitempty"`
 	Results  bool            `json:"results,omitempty"`
+	Kicked   []string        `json:"-"`                 // names of players removed from the session, used by Personalise to fill in Removed
+	Removed  bool            `json:"removed,omitempty"` // the recipient has been removed from the session and their connection is about to be closed
 }
 
 type Player struct {
 	Organiser bool   `json:"organiser"`
 	Name      string `json:"name"`
+	Team      *Team  `json:"team,omitempty"` // nil unless the session is in team mode
+}
+
+type Team struct {
+	Name   string `json:"name"`
+	Colour string `json:"colour"`
 }
 
 type QuestionUpdate struct {
-	Title         string   `json:"title"`
-	RemainingTime uint64   `json:"remainingTime"`
-	Answers       []Answer `json:"answers"`
+	Title     string `json:"title"`
+	TitleHTML string `json:"titleHtml"`       // the title rendered from Markdown, see markdown.Render
+	Image     string `json:"image,omitempty"` // URL of the image shown with the question
+	TimerUpdate
+	Answers []Answer `json:"answers"`         // empty for text questions
+	Type    string   `json:"type"`            // single, multiple (the player submits a set of answers at once), text, number, ordering or poll
+	Votes   uint8    `json:"votes,omitempty"` // the number of choices a player may vote for in a poll
+	Round   string   `json:"round,omitempty"` // the title of the round the question belongs to
+	// Answers in the order shown to each player if the order differs per player, used by Personalise to replace Answers
+	Shuffled map[string][]Answer `json:"-"`
+}
+
+// Corrects the countdown of the current question, e.g. after it has been paused
+type TimerUpdate struct {
+	RemainingTime uint64 `json:"remainingTime"` // in milliseconds
+	Paused        bool   `json:"paused"`
 }
 
 type Answer struct {
-	ID    string `json:"id"`
-	Title string `json:"title"`
+	ID        string `json:"id"`
+	Title     string `json:"title"` // may be empty if there is an image
+	TitleHTML string `json:"titleHtml"`
+	Image     string `json:"image,omitempty"`
+}
+
+type ProgressUpdate struct {
+	Answered uint64        `json:"answered"`
+	Players  uint64        `json:"players"`
+	Missing  []string      `json:"missing,omitempty"` // names of players yet to answer, sent to organisers only
+	Votes    []AnswerStats `json:"votes,omitempty"`   // the votes cast so far in a poll
 }
 
 type BreakUpdate struct {
-	//TODO add interim results
+	Title       string              `json:"title"`
+	TitleHTML   string              `json:"titleHtml"`
+	Type        string              `json:"type"` // the type of the question, see QuestionUpdate
+	Answers     []AnswerStats       `json:"answers"`
+	Variants    []VariantStats      `json:"variants,omitempty"` // answers typed to text questions
+	Number      *NumberStats        `json:"number,omitempty"`   // the answer to a number question and the guesses
+	Picks       map[string][]string `json:"-"`                  // IDs of choices (normalised variants or guesses for typed answers) picked by each player, used by Personalise to fill in Picked
+	Picked      []string            `json:"picked,omitempty"`
+	Leaderboard []Standing          `json:"leaderboard"`
+	Me          *Standing           `json:"me,omitempty"` // the recipient's own standing, filled in by Personalise
+	// why the correct answer is correct, rendered from Markdown, empty if the question has no explanation
+	ExplanationHTML string `json:"explanationHtml,omitempty"`
+	// the standings in the round ended by the question, nil unless the question is the last one of its round
+	RoundResults *RoundResults `json:"roundResults,omitempty"`
+	NextRound    *Round        `json:"nextRound,omitempty"` // the round starting with the next question, shown as its title slide
+}
+
+type Round struct {
+	Title     string `json:"title"`
+	TitleHTML string `json:"titleHtml"`
+	IntroHTML string `json:"introHtml,omitempty"`
+}
+
+type RoundResults struct {
+	Round
+	Leaderboard []Standing `json:"leaderboard"`
+	Me          *Standing  `json:"me,omitempty"` // filled in by Personalise
+}
+
+type AnswerStats struct {
+	Answer
+	Correct      bool   `json:"correct"`
+	Count        uint64 `json:"count"`                  // the number of players who picked this choice
+	FeedbackHTML string `json:"feedbackHtml,omitempty"` // shown to players who picked this choice, empty if none
+}
+
+// Counts the players, who have typed an answer, which is the same once normalised
+type VariantStats struct {
+	Text    string `json:"text"` // normalised
+	Correct bool   `json:"correct"`
+	Count   uint64 `json:"count"`
+}
+
+type NumberStats struct {
+	Value   float64   `json:"value"`
+	Margin  float64   `json:"margin"`  // zero if the closest guesses win
+	Guesses []float64 `json:"guesses"` // in ascending order
+}
+
+type Standing struct {
+	Name        string  `json:"name"`
+	Points      float64 `json:"points"`
+	Place       uint64  `json:"place"`
+	PlaceChange int64   `json:"placeChange"` // positive if the player has moved up since the previous question
+}
+
+// the number of players shown on the interim leaderboard
+const LeaderboardSize = 5
+
+// Returns a copy of su tailored to the player of the given name.
+// The model fills in data for all players, which are filtered here just before sending.
+// su is shared by all recipients and thus must not be modified.
+func (su StateUpdate) Personalise(name string, organiser bool) StateUpdate {
+	for _, kicked := range su.Kicked {
+		if kicked == name {
+			su.Removed = true
+		}
+	}
+	if su.Question != nil && !organiser {
+		if answers, ok := su.Question.Shuffled[name]; ok {
+			var qu = *su.Question
+			qu.Answers = answers
+			su.Question = &qu
+		}
+	}
+	if su.Progress != nil && !organiser {
+		var pu = *su.Progress
+		pu.Missing = nil
+		su.Progress = &pu
+	}
+	if su.Break != nil {
+		var bu = *su.Break
+		bu.Picked = bu.Picks[name]
+		bu.Me, bu.Leaderboard = personaliseLeaderboard(bu.Leaderboard, name)
+		if bu.RoundResults != nil {
+			var rr = *bu.RoundResults
+			rr.Me, rr.Leaderboard = personaliseLeaderboard(rr.Leaderboard, name)
+			bu.RoundResults = &rr
+		}
+		su.Break = &bu
+	}
+	return su
+}
+
+// Returns the standing of the player of the given name, if any, and the leaderboard cut to LeaderboardSize
+func personaliseLeaderboard(leaderboard []Standing, name string) (*Standing, []Standing) {
+	var me *Standing
+	for i := range leaderboard {
+		if leaderboard[i].Name == name {
+			var standing = leaderboard[i]
+			me = &standing
+			break
+		}
+	}
+	if len(leaderboard) > LeaderboardSize {
+		leaderboard = leaderboard[:LeaderboardSize]
+	}
+	return me, leaderboard
 }
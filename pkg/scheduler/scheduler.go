@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// Runs delayed jobs independently of the goroutines scheduling them.
+// There is at most one job pending for each id (usually a session), scheduling another one replaces it.
+type Scheduler struct {
+	sync.Mutex
+	timers map[uuid.UUID]*time.Timer
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		timers: make(map[uuid.UUID]*time.Timer),
+	}
+}
+
+// Runs job in its own goroutine at the given time, replacing the job pending for id if any.
+// A replaced job might still run if it was already due, therefore jobs shall check whether there is still something to do.
+func (s *Scheduler) Schedule(id uuid.UUID, at time.Time, job func()) {
+	s.Lock()
+	defer s.Unlock()
+	if t, ok := s.timers[id]; ok {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(time.Until(at), func() {
+		s.Lock()
+		if s.timers[id] == t {
+			delete(s.timers, id)
+		}
+		s.Unlock()
+		job()
+	})
+	s.timers[id] = t
+}
+
+// Cancels the job pending for id if any
+func (s *Scheduler) Cancel(id uuid.UUID) {
+	s.Lock()
+	defer s.Unlock()
+	if t, ok := s.timers[id]; ok {
+		t.Stop()
+		delete(s.timers, id)
+	}
+}
+
+// Returns the number of pending jobs
+func (s *Scheduler) Pending() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.timers)
+}
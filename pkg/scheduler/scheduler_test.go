@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Schedule(t *testing.T) {
+	s := NewScheduler()
+	id := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	done := make(chan int, 2)
+
+	s.Schedule(id, time.Now().Add(time.Hour), func() { done <- 1 })
+	s.Schedule(id, time.Now().Add(10*time.Millisecond), func() { done <- 2 })
+	if pending := s.Pending(); pending != 1 {
+		t.Fatalf("Expected exactly one pending job, got %d", pending)
+	}
+
+	select {
+	case job := <-done:
+		if job != 2 {
+			t.Fatalf("The replaced job %d has run", job)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("The scheduled job has not run in time")
+	}
+	time.Sleep(10 * time.Millisecond) // let the timer clean up after itself
+	if pending := s.Pending(); pending != 0 {
+		t.Fatalf("Expected no pending jobs after running the job, got %d", pending)
+	}
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	s := NewScheduler()
+	id := uuid.MustParse("b3d2f5b2-d5eb-4461-b352-622431a35b12")
+	done := make(chan struct{}, 1)
+
+	s.Schedule(id, time.Now().Add(10*time.Millisecond), func() { done <- struct{}{} })
+	s.Cancel(id)
+
+	select {
+	case <-done:
+		t.Fatalf("A cancelled job has run")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if pending := s.Pending(); pending != 0 {
+		t.Fatalf("Expected no pending jobs after cancelling, got %d", pending)
+	}
+}
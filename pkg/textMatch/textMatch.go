@@ -0,0 +1,110 @@
+package textMatch
+
+import (
+	"strings"
+	"unicode"
+)
+
+// letters with diacritics mapped to their base letters, covering Czech, Slovak and the most common western and central European languages
+var folding = map[rune]rune{}
+
+func init() {
+	var pairs = [...][2]string{
+		{"áàâäãåāăą", "a"},
+		{"çćčĉċ", "c"},
+		{"ďđ", "d"},
+		{"éèêëēĕėęě", "e"},
+		{"ĝğġģ", "g"},
+		{"ĥħ", "h"},
+		{"íìîïĩīĭįı", "i"},
+		{"ĵ", "j"},
+		{"ķ", "k"},
+		{"ĺļľŀł", "l"},
+		{"ñńņňŉ", "n"},
+		{"óòôöõøōŏő", "o"},
+		{"ŕŗř", "r"},
+		{"śŝşšș", "s"},
+		{"ţťŧț", "t"},
+		{"úùûüũūŭůűų", "u"},
+		{"ŵ", "w"},
+		{"ýÿŷ", "y"},
+		{"źżž", "z"},
+	}
+	for _, p := range pairs {
+		for _, r := range p[0] {
+			folding[r] = rune(p[1][0])
+		}
+	}
+}
+
+// sentence punctuation players tend to type around an answer, other characters such as in C++ or C# belong to it
+const endPunctuation = ".,;:!?…\"'„“”‚‘’«»()"
+
+// Returns s in a form suitable for comparing answers typed by players.
+// Letters are lowercased and stripped of diacritics, whitespace is trimmed and collapsed into single spaces
+// and sentence punctuation is dropped from both ends. Punctuation inside the answer is kept.
+func Normalise(s string) string {
+	var b strings.Builder
+	var space bool
+	for _, r := range strings.ToLower(s) {
+		if f, ok := folding[r]; ok {
+			r = f
+		}
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+		} else {
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return strings.Trim(b.String(), " "+endPunctuation)
+}
+
+// how many characters of the accepted answer each forgiven typo needs, so that short answers cannot be matched by unrelated words
+const lettersPerTypo = 4
+
+// Reports whether answer matches accepted after both have been normalised.
+// Up to tolerance single-letter insertions, deletions or substitutions are forgiven,
+// but no more than one per lettersPerTypo characters of the normalised accepted answer.
+func Matches(answer string, accepted string, tolerance uint) bool {
+	var a, b = Normalise(answer), Normalise(accepted)
+	var runes = []rune(b)
+	tolerance = minOf(tolerance, uint(len(runes)/lettersPerTypo))
+	if tolerance == 0 || a == b {
+		return a == b
+	}
+	return distance([]rune(a), runes) <= tolerance
+}
+
+// Computes the Levenshtein distance of a and b
+func distance(a []rune, b []rune) uint {
+	var previous = make([]uint, len(b)+1)
+	var current = make([]uint, len(b)+1)
+	for j := range previous {
+		previous[j] = uint(j)
+	}
+	for i := range a {
+		current[0] = uint(i + 1)
+		for j := range b {
+			var substitution = previous[j]
+			if a[i] != b[j] {
+				substitution++
+			}
+			current[j+1] = minOf(substitution, previous[j+1]+1, current[j]+1)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minOf(x uint, others ...uint) uint {
+	for _, o := range others {
+		if o < x {
+			x = o
+		}
+	}
+	return x
+}
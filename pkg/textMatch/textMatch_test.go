@@ -0,0 +1,57 @@
+package textMatch
+
+import "testing"
+
+func TestNormalise(t *testing.T) {
+	var cases = map[string]string{
+		"Canberra":                  "canberra",
+		"  Příliš   žluťoučký kůň ": "prilis zlutoucky kun",
+		"ŘEŘICHA":                   "rericha",
+		"J. R. R. Tolkien":          "j. r. r. tolkien",
+		"rock'n'roll!":              "rock'n'roll",
+		"\t1945\n":                  "1945",
+		"„Praha“ .":                 "praha",
+		"C++":                       "c++",
+		"C#":                        "c#",
+		"3.14":                      "3.14",
+		"?!":                        "",
+		"":                          "",
+	}
+	for s, expected := range cases {
+		if actual := Normalise(s); actual != expected {
+			t.Errorf("Normalise(%q) returned %q while %q was expected", s, actual, expected)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	var cases = []struct {
+		answer    string
+		accepted  string
+		tolerance uint
+		expected  bool
+	}{
+		{"canberra", "Canberra", 0, true},
+		{"Canbera", "Canberra", 0, false},
+		{"Canbera", "Canberra", 1, true},
+		{"Kanbera", "Canberra", 1, false},
+		{"Kanbera", "Canberra", 2, true},
+		{"Brno", "Praha", 2, false},
+		{"Plzen", "Plzeň", 0, true},
+		{"C", "C++", 0, false},
+		{"C", "C#", 0, false},
+		{"314", "3.14", 0, false},
+		{"Praha.", "Praha", 0, true},
+		{"Brno", "Brno", 3, true},
+		{"Brod", "Brno", 3, false},
+		{"Brnoo", "Brno", 3, true},
+		{"Cu", "Au", 1, false},
+		{"Kanbera", "Canberra", 3, true},
+		{"Kanbra", "Canberra", 3, false},
+	}
+	for _, c := range cases {
+		if actual := Matches(c.answer, c.accepted, c.tolerance); actual != c.expected {
+			t.Errorf("Matches(%q, %q, %d) returned %v while %v was expected", c.answer, c.accepted, c.tolerance, actual, c.expected)
+		}
+	}
+}